@@ -0,0 +1,345 @@
+/*
+Copyright © 2023 Lyu Lin <lvlin@whu.edu.cn>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackeylu/mytools/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// the attendance statuses of a student
+const (
+	statusPresent = "present"
+	statusAbsent  = "absent"
+	statusLate    = "late"
+	statusExcused = "excused"
+	// attendanceItem is the weight key of the attendance in the grading policy
+	attendanceItem = "attendance"
+)
+
+// statusLabels are the labels of the statuses in the workbook
+var statusLabels = map[string]string{
+	statusPresent: "出勤",
+	statusAbsent:  "缺勤",
+	statusLate:    "迟到",
+	statusExcused: "请假",
+}
+
+var (
+	attendanceCourseID  string
+	attendanceDate      string
+	attendanceChecklist bool
+	attendanceOutput    string
+)
+
+// attendanceBook keeps the attendance records of a course
+type attendanceBook struct {
+	Course string `json:"course"`
+	// Records are keyed by the date, e.g. "2023-09-01", and then the student's sno
+	Records map[string]map[string]string `json:"records"`
+}
+
+// attendanceCmd represents the attendance command
+var attendanceCmd = &cobra.Command{
+	Use:   "attendance",
+	Short: "课堂点名考勤，记录出勤、缺勤、迟到和请假，并导出学期考勤表.",
+	Long: `Take the attendance of the students of a course, and export the attendance workbook of the semester.
+
+The records are stored in '<course>.attendance.json' of the directory 'attendance.dir', default is the
+current directory. The attendance takes part in the final grade with the weight 'attendance' of the
+grading policy, for example:
+
+course:
+  php2023:
+    grading:
+      weights: {lab1: 0.4, lab2: 0.4, attendance: 0.2}
+      attendance-late: 0.5
+
+The attendance score is 100 * (present + late * attendance-late) / (dates - excused), the dates without
+the record of a student, e.g. after q in the roll call, are skipped as excused and left blank in the workbook.`,
+}
+
+var attendanceTakeCmd = &cobra.Command{
+	Use:   "take",
+	Short: "逐个或按清单点名.",
+	Long: `Take the attendance of a date, the students are shown one at a time to input the status:
+Enter or p for present, a for absent, l for late, e for excused, and q to stop.
+
+With --checklist, all the students are listed, and the numbers of the absent, late and excused
+students are input, the others are present.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := time.Parse("2006-01-02", attendanceDate); err != nil {
+			return fmt.Errorf("illegal date %s, expecting 2006-01-02", attendanceDate)
+		}
+		course, err := loadCourse(attendanceCourseID)
+		if err != nil {
+			return err
+		}
+		book, err := readAttendance(attendanceCourseID)
+		if err != nil {
+			return err
+		}
+		var records map[string]string
+		if attendanceChecklist {
+			records, err = takeChecklist(os.Stdin, course.CourseStudents)
+		} else {
+			records, err = takeOneByOne(os.Stdin, course.CourseStudents)
+		}
+		if err != nil {
+			return err
+		}
+		if book.Records[attendanceDate] == nil {
+			book.Records[attendanceDate] = make(map[string]string)
+		}
+		counts := make(map[string]int)
+		for sno, status := range records {
+			book.Records[attendanceDate][sno] = status
+			counts[status]++
+		}
+		if err := writeAttendance(book); err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d present, %d absent, %d late, %d excused.\n", attendanceDate,
+			counts[statusPresent], counts[statusAbsent], counts[statusLate], counts[statusExcused])
+		return nil
+	},
+}
+
+var attendanceExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "导出学期考勤表.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		course, err := loadCourse(attendanceCourseID)
+		if err != nil {
+			return err
+		}
+		book, err := readAttendance(attendanceCourseID)
+		if err != nil {
+			return err
+		}
+		if attendanceOutput == "" {
+			attendanceOutput = attendanceCourseID + "-attendance.xlsx"
+		}
+		if err := util.WriteExcelSheets(attendanceOutput, attendanceSheets(course.CourseStudents, book)); err != nil {
+			return err
+		}
+		fmt.Println("The attendance is saved in", attendanceOutput)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(attendanceCmd)
+	attendanceCmd.AddCommand(attendanceTakeCmd, attendanceExportCmd)
+
+	attendanceCmd.PersistentFlags().StringVar(&attendanceCourseID, "course", "", "the course id in the configuration file")
+	attendanceCmd.MarkPersistentFlagRequired("course")
+	attendanceTakeCmd.Flags().StringVar(&attendanceDate, "date", time.Now().Format("2006-01-02"), "the date of the attendance")
+	attendanceTakeCmd.Flags().BoolVar(&attendanceChecklist, "checklist", false, "list all the students and input the numbers of the absent ones")
+	attendanceExportCmd.Flags().StringVarP(&attendanceOutput, "output", "o", "", "the xlsx file, default is <course>-attendance.xlsx")
+}
+
+func attendanceFile(courseID string) string {
+	dir := viper.GetString("attendance.dir")
+	if dir == "" {
+		dir = "."
+	}
+	return filepath.Join(dir, courseID+".attendance.json")
+}
+
+func readAttendance(courseID string) (*attendanceBook, error) {
+	book := &attendanceBook{Course: courseID, Records: make(map[string]map[string]string)}
+	data, err := os.ReadFile(attendanceFile(courseID))
+	if os.IsNotExist(err) {
+		return book, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, book); err != nil {
+		return nil, fmt.Errorf("broken attendance %s: %w", attendanceFile(courseID), err)
+	}
+	if book.Records == nil {
+		book.Records = make(map[string]map[string]string)
+	}
+	return book, nil
+}
+
+func writeAttendance(book *attendanceBook) error {
+	data, err := json.MarshalIndent(book, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(attendanceFile(book.Course), data, 0644)
+}
+
+// parseStatus parses the input of a status, an empty input is present
+func parseStatus(input string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(input)) {
+	case "", "p":
+		return statusPresent, true
+	case "a":
+		return statusAbsent, true
+	case "l":
+		return statusLate, true
+	case "e":
+		return statusExcused, true
+	}
+	return "", false
+}
+
+// takeOneByOne asks the status of every student in turn, it stops at q or the end of the input,
+// and the students not asked are not recorded, they are not counted in the score of the date.
+func takeOneByOne(r io.Reader, students []CourseStudent) (map[string]string, error) {
+	records := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for i := 0; i < len(students); {
+		student := students[i]
+		fmt.Printf("[%d/%d] %s %s %s (Enter/p/a/l/e/q): ", i+1, len(students), student.Class, student.Name, student.Sno)
+		if !scanner.Scan() {
+			fmt.Println()
+			break
+		}
+		if strings.TrimSpace(scanner.Text()) == "q" {
+			break
+		}
+		status, ok := parseStatus(scanner.Text())
+		if !ok {
+			fmt.Println("Illegal input, expecting Enter, p, a, l, e or q.")
+			continue
+		}
+		records[student.Sno] = status
+		i++
+	}
+	return records, scanner.Err()
+}
+
+// takeChecklist lists the students, and reads the numbers of the absent, late and excused students
+// in three lines, the others are present.
+func takeChecklist(r io.Reader, students []CourseStudent) (map[string]string, error) {
+	for i, student := range students {
+		fmt.Printf("%3d. %s %s\n", i+1, student.Name, student.Sno)
+	}
+	records := make(map[string]string)
+	for _, s := range students {
+		records[s.Sno] = statusPresent
+	}
+	scanner := bufio.NewScanner(r)
+	for _, status := range []string{statusAbsent, statusLate, statusExcused} {
+		fmt.Printf("%s的序号(逗号或空格分隔): ", statusLabels[status])
+		if !scanner.Scan() {
+			fmt.Println()
+			break
+		}
+		indexes, err := parseIndexes(scanner.Text(), len(students))
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range indexes {
+			records[students[i-1].Sno] = status
+		}
+	}
+	return records, scanner.Err()
+}
+
+// parseIndexes parses the numbers between 1 and n separated by commas or spaces
+func parseIndexes(input string, n int) ([]int, error) {
+	var indexes []int
+	for _, field := range strings.FieldsFunc(input, func(r rune) bool { return r == ',' || r == '，' || r == ' ' }) {
+		i, err := strconv.Atoi(field)
+		if err != nil || i < 1 || i > n {
+			return nil, fmt.Errorf("illegal number %s, expecting 1 to %d", field, n)
+		}
+		indexes = append(indexes, i)
+	}
+	return indexes, nil
+}
+
+// attendanceDates returns the dates of the records in order
+func attendanceDates(book *attendanceBook) []string {
+	var dates []string
+	for date := range book.Records {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	return dates
+}
+
+// attendanceSheets builds the roster × dates sheet with the counts of the statuses
+func attendanceSheets(students []CourseStudent, book *attendanceBook) []util.Sheet {
+	dates := attendanceDates(book)
+	sheet := util.Sheet{Name: "考勤", Headers: append([]string{"学号", "姓名", "班级"}, dates...)}
+	sheet.Headers = append(sheet.Headers, "缺勤", "迟到", "请假")
+	for i := 0; i < 3; i++ {
+		sheet.NumericColumns = append(sheet.NumericColumns, 3+len(dates)+i)
+	}
+	for _, s := range students {
+		row := []string{s.Sno, s.Name, s.Class}
+		counts := make(map[string]int)
+		for _, date := range dates {
+			status := book.Records[date][s.Sno]
+			counts[status]++
+			row = append(row, statusLabels[status])
+		}
+		row = append(row, strconv.Itoa(counts[statusAbsent]), strconv.Itoa(counts[statusLate]), strconv.Itoa(counts[statusExcused]))
+		sheet.Rows = append(sheet.Rows, row)
+	}
+	return []util.Sheet{sheet}
+}
+
+// attendanceScores scores the attendance of the students as 100 * (present + late * lateCredit) / (dates - excused),
+// a date without the record of a student is skipped as excused, the same as the blank cell of the workbook.
+func attendanceScores(students []CourseStudent, book *attendanceBook, lateCredit float64) map[string]labScore {
+	scores := make(map[string]labScore)
+	dates := attendanceDates(book)
+	for _, s := range students {
+		var attended, total float64
+		for _, date := range dates {
+			switch book.Records[date][s.Sno] {
+			case statusPresent:
+				attended++
+			case statusLate:
+				attended += lateCredit
+			case statusExcused, "":
+				continue
+			}
+			total++
+		}
+		score := 100.0
+		if total > 0 {
+			score = 100 * attended / total
+		}
+		scores[s.Sno] = labScore{Score: score}
+	}
+	return scores
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTakeAttendance(t *testing.T) {
+	// an illegal input is asked again, and q stops the roll call
+	records, err := takeOneByOne(strings.NewReader("\nx\nl\nq\n"), labStudents)
+	expected := map[string]string{"2200001": statusPresent, "2200002": statusLate}
+	if err != nil || !reflect.DeepEqual(records, expected) {
+		t.Errorf("Expected %v, but got %v, %v", expected, records, err)
+	}
+
+	records, err = takeChecklist(strings.NewReader("3\n\n1\n"), labStudents)
+	expected = map[string]string{"2200001": statusExcused, "2200002": statusPresent, "2200003": statusAbsent}
+	if err != nil || !reflect.DeepEqual(records, expected) {
+		t.Errorf("Expected %v, but got %v, %v", expected, records, err)
+	}
+	if _, err := takeChecklist(strings.NewReader("4\n"), labStudents); err == nil {
+		t.Error("Expected an error of the illegal number")
+	}
+}
+
+func TestAttendanceSheets(t *testing.T) {
+	book := &attendanceBook{Records: map[string]map[string]string{
+		"2023-09-08": {"2200001": statusLate, "2200002": statusExcused, "2200003": statusAbsent},
+		"2023-09-01": {"2200001": statusPresent, "2200002": statusPresent},
+	}}
+	sheets := attendanceSheets(labStudents, book)
+	expected := [][]string{
+		{"2200001", "张三", "", "出勤", "迟到", "0", "1", "0"},
+		{"2200002", "李四", "", "出勤", "请假", "0", "0", "1"},
+		{"2200003", "赵六", "", "", "缺勤", "1", "0", "0"},
+	}
+	if !reflect.DeepEqual(sheets[0].Rows, expected) {
+		t.Errorf("Expected %v, but got %v", expected, sheets[0].Rows)
+	}
+
+	// the blank date of 赵六 is neither counted as absent in the sheet nor in the score
+	scores := attendanceScores(labStudents, book, 0.5)
+	for sno, score := range map[string]float64{"2200001": 75, "2200002": 100, "2200003": 0} {
+		if scores[sno].Score != score {
+			t.Errorf("Expected the attendance score %v of %s, but got %v", score, sno, scores[sno].Score)
+		}
+	}
+}
+
+func TestAttendanceUnrecorded(t *testing.T) {
+	// q stops the roll call after the first student
+	records, err := takeOneByOne(strings.NewReader("a\nq\n"), labStudents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	book := &attendanceBook{Records: map[string]map[string]string{
+		"2023-09-01": {"2200001": statusPresent, "2200002": statusPresent, "2200003": statusPresent},
+		"2023-09-08": records,
+	}}
+	sheet := attendanceSheets(labStudents, book)[0]
+	scores := attendanceScores(labStudents, book, 0.5)
+	for i, s := range labStudents {
+		absent := sheet.Rows[i][5]
+		// a student scored below 100 with a single present date must be absent in the sheet
+		if (scores[s.Sno].Score < 100) != (absent != "0") {
+			t.Errorf("Expected the same rule of %s in the sheet %v and the score %v", s.Name, sheet.Rows[i], scores[s.Sno].Score)
+		}
+	}
+	if scores["2200001"].Score != 50 || scores["2200003"].Score != 100 || sheet.Rows[2][4] != "" {
+		t.Errorf("Expected 张三 absent and 赵六 unrecorded, but got %v and %v", scores, sheet.Rows)
+	}
+}
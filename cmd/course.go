@@ -24,6 +24,8 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"math"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
@@ -35,10 +37,14 @@ import (
 
 var (
 	emailFile string
+	// results with a confidence lower than minConfidence are moved to the review sheet
+	minConfidence float64
 )
 
 // Course is the course settings from configuration file
 type Course struct {
+	// ID is the key of the course in the configuration file
+	ID string
 	// CourseName is the name of the course
 	CourseName string
 	// Labs is the list of labs
@@ -67,6 +73,10 @@ type emailResult struct {
 	Attachment string
 	// Notes is the notes of the processing result
 	Notes string
+	// Confidence is a score in [0, 1] telling how reliable the result is
+	Confidence float64
+	// Explanation lists the evidences behind the confidence
+	Explanation string
 }
 
 // courseCmd represents the course command
@@ -79,11 +89,13 @@ The "course" command is used to find the student name, student ID, and course fr
 
 Example:
 
-$ email course -f emails.xlsx
+$ email course -f emails.xlsx --min-confidence 0.6
 
 The output will be like:
 
-姓名	学号	课程   实验名   提交时间  提交人邮件地址 邮件主题  附件名
+姓名	学号	课程   实验名   提交时间  提交人邮件地址 邮件主题  附件名  备注  置信度  依据
+
+Results with a confidence lower than --min-confidence are moved to the "待复核" sheet.
 
 to quickly create a Cobra application.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -100,7 +112,9 @@ to quickly create a Cobra application.`,
 			log.Println(err)
 			return err
 		}
-		//fmt.Println(result)
+		for _, group := range identicalAttachments(emails) {
+			log.Println("Identical attachments in different emails:", strings.Join(group, " | "))
+		}
 		var courseInfo []Course
 		if err := readCourseFile(&courseInfo); err != nil {
 			return err
@@ -111,31 +125,40 @@ to quickly create a Cobra application.`,
 		result := updateEmailResultWithCourseInfo(emails, labsMap)
 
 		// fmt.Println(result)
-		saveResult(result)
-
-		return nil
+		return saveResult(result, minConfidence)
 	},
 }
 
 func init() {
 	emailCmd.AddCommand(courseCmd)
 	courseCmd.Flags().StringVarP(&emailFile, "file", "f", "email.xlsx", "the fetched email file by email command")
+	courseCmd.Flags().Float64Var(&minConfidence, "min-confidence", 0,
+		"results with a lower confidence are written to the review sheet")
 }
 
-func saveResult(result []emailResult) {
-	header := []string{"姓名", "学号", "课程", "实验名", "提交时间", "提交人邮件地址", "邮件主题", "附件名", "备注"}
-	columns := make([][]string, len(result))
-	for i, v := range result {
-		columns[i] = []string{v.StudentName, v.StudentID, v.Course, v.Lab, v.Time, v.Email, v.Subject, v.Attachment, v.Notes}
+func saveResult(result []emailResult, minConfidence float64) error {
+	header := []string{"姓名", "学号", "课程", "实验名", "提交时间", "提交人邮件地址", "邮件主题", "附件名", "备注", "置信度", "依据"}
+	var accepted, review [][]string
+	for _, v := range result {
+		row := []string{v.StudentName, v.StudentID, v.Course, v.Lab, v.Time, v.Email, v.Subject, v.Attachment, v.Notes,
+			strconv.FormatFloat(v.Confidence, 'f', 2, 64), v.Explanation}
+		if v.Confidence < minConfidence {
+			review = append(review, row)
+		} else {
+			accepted = append(accepted, row)
+		}
 	}
-	util.WriteExcelFile("email_course.xlsx", header, columns)
+	return util.WriteExcelSheets("email_course.xlsx", []util.Sheet{
+		{Name: "结果", Headers: header, Rows: accepted},
+		{Name: "待复核", Headers: header, Rows: review},
+	})
 }
 
 // readAttachmentEmailFromFetchedEmailFile reads the fetched email file, and build the preliminary result
 func readAttachmentEmailFromFetchedEmailFile(emailFile string, emails *[]EmailInfo) error {
-	util.ReadExcelFile(emailFile, func(row int, columns []string) error {
+	return util.ReadExcelFile(emailFile, func(row int, columns []string) error {
 		if row == 0 {
-			if reflect.DeepEqual(columns, ExcelFileHeader()) {
+			if reflect.DeepEqual(columns, ExcelFileHeader()) || reflect.DeepEqual(columns, legacyExcelFileHeader()) {
 				return nil
 			} else {
 				return fmt.Errorf("邮件标题不匹配，应当是%v", ExcelFileHeader())
@@ -151,51 +174,97 @@ func readAttachmentEmailFromFetchedEmailFile(emailFile string, emails *[]EmailIn
 			return err
 		}
 		// handle the contents
-		*emails = append(*emails, EmailInfo{
+		info := EmailInfo{
 			SeqNum:      uint32(num),
 			Date:        date,
 			From:        columns[2],
 			To:          strings.Split(columns[3], ","),
 			Subject:     columns[4],
 			Attachments: DecodeAttachments(columns[5]),
-		})
+		}
+		if len(columns) > 6 {
+			info.AttachmentHashes = DecodeAttachments(columns[6])
+		}
+		*emails = append(*emails, info)
 		return nil
 	}, false)
-	return nil
 }
 
 func readCourseFile(courseInfo *[]Course) error {
 	dataset := viper.GetStringMap("course")
-	for _, value := range dataset {
+	for key, value := range dataset {
 		// fmt.Println(key, value)
 		if reflect.TypeOf(value).Kind() != reflect.Map {
 			return fmt.Errorf("课程配置错误，应该是map")
 		}
-		value := value.(map[string]interface{})
-		course := Course{
-			CourseName: value["name"].(string),
-		}
-		labs := value["labs"].([]interface{})
-		for _, lab := range labs {
-			if reflect.TypeOf(lab).Kind() != reflect.String {
-				return fmt.Errorf("实验配置错误，应该是string")
-			}
-			course.Labs = append(course.Labs, lab.(string))
-		}
-		classes := value["classes"].([]interface{})
-		for _, class := range classes {
-			if reflect.TypeOf(class).Kind() != reflect.String {
-				return fmt.Errorf("班级配置错误，应该是string")
-			}
-			course.CourseStudents = append(course.CourseStudents, ReadNameList(class.(string))...)
+		course, err := parseCourse(key, value.(map[string]interface{}))
+		if err != nil {
+			return err
 		}
-
 		*courseInfo = append(*courseInfo, course)
 	}
 
 	return nil
 }
 
+// loadCourse reads the course with the given id from the configuration file
+func loadCourse(id string) (Course, error) {
+	value := viper.GetStringMap("course." + id)
+	if len(value) == 0 {
+		// the course may be recorded in the registry only
+		if registry, err := loadRegistry(); err == nil && len(registry.Courses[id]) > 0 {
+			return parseCourse(id, map[string]interface{}{"name": id})
+		}
+		return Course{}, fmt.Errorf("课程%s未配置", id)
+	}
+	return parseCourse(id, value)
+}
+
+// parseCourse builds the course from its settings, the students are read from the namelist
+// files of the classes, and the class of a student is the namelist filename without extension.
+// A class which is not a file is read from the registry, and the classes of the registry are
+// taken when the course has no classes.
+func parseCourse(id string, value map[string]interface{}) (Course, error) {
+	name, _ := value["name"].(string)
+	course := Course{
+		ID:         id,
+		CourseName: name,
+	}
+	labs, _ := value["labs"].([]interface{})
+	for _, lab := range labs {
+		if reflect.TypeOf(lab).Kind() != reflect.String {
+			return course, fmt.Errorf("实验配置错误，应该是string")
+		}
+		course.Labs = append(course.Labs, lab.(string))
+	}
+	classes, _ := value["classes"].([]interface{})
+	if len(classes) == 0 {
+		registry, err := loadRegistry()
+		if err != nil {
+			return course, err
+		}
+		for _, class := range registry.Courses[id] {
+			classes = append(classes, class)
+		}
+	}
+	for _, class := range classes {
+		if reflect.TypeOf(class).Kind() != reflect.String {
+			return course, fmt.Errorf("班级配置错误，应该是string")
+		}
+		classFile := class.(string)
+		className := strings.TrimSuffix(filepath.Base(classFile), filepath.Ext(classFile))
+		students, err := readClassRoster(classFile)
+		if err != nil {
+			return course, err
+		}
+		for _, student := range students {
+			student.Class = className
+			course.CourseStudents = append(course.CourseStudents, student)
+		}
+	}
+	return course, nil
+}
+
 func updateEmailResultWithCourseInfo(emails []EmailInfo, labsMap map[string]Course) []emailResult {
 	var ans []emailResult
 	for _, email := range emails {
@@ -211,17 +280,18 @@ func findAndBuildResults(email EmailInfo, labsMap map[string]Course) []emailResu
 		log.Printf("Failed to find student name and ID from email %v: %v\n", email, err)
 		return []emailResult{
 			{
-				Time:       email.Date.Format("2006-01-02 15:04:05"),
-				Email:      email.From,
-				Subject:    email.Subject,
-				Attachment: EncodeAttachments(email.Attachments),
-				Notes:      "Failed",
+				Time:        email.Date.Format("2006-01-02 15:04:05"),
+				Email:       email.From,
+				Subject:     email.Subject,
+				Attachment:  EncodeAttachments(email.Attachments),
+				Notes:       "Failed",
+				Explanation: err.Error(),
 			},
 		}
 	}
 	var results []emailResult
 	for _, lab := range labs {
-		// TODO validate the sno and name and labname
+		confidence, explanation := scoreResult(email, name, id, lab, labsMap[lab])
 		results = append(results, emailResult{
 			StudentName: name,
 			StudentID:   id,
@@ -232,6 +302,8 @@ func findAndBuildResults(email EmailInfo, labsMap map[string]Course) []emailResu
 			Subject:     email.Subject,
 			Attachment:  EncodeAttachments(email.Attachments),
 			Notes:       "Success",
+			Confidence:  confidence,
+			Explanation: explanation,
 		},
 		)
 	}
@@ -239,6 +311,86 @@ func findAndBuildResults(email EmailInfo, labsMap map[string]Course) []emailResu
 	return results
 }
 
+// the weight of every evidence used by scoreResult, the total is 1.0
+const (
+	labExactScore     = 0.4
+	labFuzzyScore     = 0.2
+	snoFoundScore     = 0.2
+	snoGuessedScore   = 0.1
+	rosterPairScore   = 0.4
+	rosterPartlyScore = 0.2
+)
+
+// scoreResult tells how reliable the classified name, sno and lab of an email are,
+// and explains where every piece of them comes from, e.g.
+// "lab from subject exact match; sno from attachment; name verified in roster".
+func scoreResult(email EmailInfo, name, id, lab string, course Course) (float64, string) {
+	var score float64
+	var reasons []string
+
+	subject := strings.ToUpper(cleanStudentProjectName(email.Subject))
+	upperLab := strings.ToUpper(lab)
+	switch {
+	case strings.Contains(subject, upperLab):
+		score += labExactScore
+		reasons = append(reasons, "lab from subject exact match")
+	case containsInAny(email.Attachments, upperLab):
+		score += labExactScore
+		reasons = append(reasons, "lab from attachment exact match")
+	default:
+		score += labFuzzyScore
+		reasons = append(reasons, "lab from longest common substring")
+	}
+
+	switch {
+	case id == "":
+		reasons = append(reasons, "sno missing")
+	case strings.Contains(subject, id):
+		score += snoFoundScore
+		reasons = append(reasons, "sno from subject")
+	case containsInAny(email.Attachments, id):
+		score += snoFoundScore
+		reasons = append(reasons, "sno from attachment")
+	default:
+		// the sno is found elsewhere, e.g. the body or the sender, it is less reliable
+		score += snoGuessedScore
+		reasons = append(reasons, "sno guessed")
+	}
+
+	sameSno, sameName := -1, -1
+	if id != "" {
+		sameSno = findUnique(course.CourseStudents, func(s CourseStudent) bool { return s.Sno == id })
+	}
+	// the name is looked up only without the sno, a namesake is not taken for the student
+	if sameSno == -1 && name != "" {
+		sameName = findUnique(course.CourseStudents, func(s CourseStudent) bool { return s.Name == name })
+	}
+	switch {
+	case sameSno != -1 && course.CourseStudents[sameSno].Name == name:
+		score += rosterPairScore
+		reasons = append(reasons, "name verified in roster")
+	case sameSno != -1:
+		score += rosterPartlyScore
+		reasons = append(reasons, fmt.Sprintf("sno in roster but name is %s", course.CourseStudents[sameSno].Name))
+	case sameName != -1:
+		score += rosterPartlyScore
+		reasons = append(reasons, fmt.Sprintf("name in roster but sno is %s", course.CourseStudents[sameName].Sno))
+	default:
+		reasons = append(reasons, "not found in roster")
+	}
+
+	return math.Round(score*100) / 100, strings.Join(reasons, "; ")
+}
+
+func containsInAny(list []string, upperSubstr string) bool {
+	for _, v := range list {
+		if strings.Contains(strings.ToUpper(v), upperSubstr) {
+			return true
+		}
+	}
+	return false
+}
+
 func findLab(subjectOrFilename string, labsMap map[string]Course) (fullLabName, removedLabName, courseName string, err error) {
 	fullLabName, removedLabName, courseName, err = "", "", "", nil
 	longestSubstr := ""
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -380,3 +381,77 @@ func TestExtractStudentNameAndIDRegex(t *testing.T) {
 		}
 	}
 }
+
+func TestScoreResult(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		email       EmailInfo
+		name        string
+		id          string
+		lab         string
+		confidence  float64
+		explanation string
+	}{
+		{
+			desc:        "主题中完整匹配且在名单中",
+			email:       EmailInfo{Subject: "220301093-易思敏-Lab1-PHP开发环境搭建", Attachments: []string{"易思敏.zip"}},
+			name:        "易思敏",
+			id:          "220301093",
+			lab:         "Lab1-PHP开发环境搭建",
+			confidence:  1,
+			explanation: "lab from subject exact match; sno from subject; name verified in roster",
+		},
+		{
+			desc:        "附件中匹配，姓名与名单不一致",
+			email:       EmailInfo{Subject: "实验报告", Attachments: []string{"220301093-易思-Lab1-PHP开发环境搭建.doc"}},
+			name:        "易思",
+			id:          "220301093",
+			lab:         "Lab1-PHP开发环境搭建",
+			confidence:  0.8,
+			explanation: "lab from attachment exact match; sno from attachment; sno in roster but name is 易思敏",
+		},
+		{
+			desc:        "学号不在主题和附件中",
+			email:       EmailInfo{Subject: "易思敏-Lab1-PHP开发环境搭建", Attachments: []string{"易思敏.zip"}},
+			name:        "易思敏",
+			id:          "220301093",
+			lab:         "Lab1-PHP开发环境搭建",
+			confidence:  0.9,
+			explanation: "lab from subject exact match; sno guessed; name verified in roster",
+		},
+		{
+			desc:        "公共子串匹配，缺少学号",
+			email:       EmailInfo{Subject: "项升杰-PHP基础知识", Attachments: []string{"项升杰.doc"}},
+			name:        "项升杰",
+			lab:         "Lab2-PHP基础知识",
+			confidence:  0.2,
+			explanation: "lab from longest common substring; sno missing; not found in roster",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			confidence, explanation := scoreResult(tC.email, tC.name, tC.id, tC.lab, labMaps[tC.lab])
+			if confidence != tC.confidence {
+				t.Errorf("Expected confidence %v, but got %v", tC.confidence, confidence)
+			}
+			if explanation != tC.explanation {
+				t.Errorf("Expected explanation [%s], but got [%s]", tC.explanation, explanation)
+			}
+		})
+	}
+}
+
+func TestScoreResultNamesake(t *testing.T) {
+	course := Course{CourseStudents: []CourseStudent{
+		{Name: "张三", Sno: "2200001"}, {Name: "张三", Sno: "2200002"}, {Name: "李四", Sno: "2200003"},
+	}}
+	email := EmailInfo{Subject: "2200001-张三-lab1"}
+	if _, explanation := scoreResult(email, "张三", "2200001", "lab1", course); !strings.HasSuffix(explanation, "name verified in roster") {
+		t.Errorf("Expected the name verified, but got [%s]", explanation)
+	}
+	// a namesake is not unique to tell the sno
+	email = EmailInfo{Subject: "张三-lab1"}
+	if _, explanation := scoreResult(email, "张三", "", "lab1", course); !strings.HasSuffix(explanation, "not found in roster") {
+		t.Errorf("Expected not found in roster, but got [%s]", explanation)
+	}
+}
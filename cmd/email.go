@@ -22,6 +22,8 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"log"
@@ -40,9 +42,14 @@ import (
 )
 
 // ExcelFileHeader returns the excel file headers
-// "SeqNum", "Date", "From", "To", "Subject", "Attachments"
+// "SeqNum", "Date", "From", "To", "Subject", "Attachments", "AttachmentHashes"
 func ExcelFileHeader() []string {
-	return []string{"SeqNum", "Date", "From", "To", "Subject", "Attachments"}
+	return []string{"SeqNum", "Date", "From", "To", "Subject", "Attachments", "AttachmentHashes"}
+}
+
+// legacyExcelFileHeader is the header of the files fetched before the attachments were hashed
+func legacyExcelFileHeader() []string {
+	return ExcelFileHeader()[:6]
 }
 
 var (
@@ -227,6 +234,9 @@ func fetchAndSaveEmails() {
 		log.Fatal(err)
 	}
 
+	for _, group := range identicalAttachments(result) {
+		log.Println("Identical attachments in different emails:", strings.Join(group, " | "))
+	}
 	util.WriteOrAppendExcelFile("email.xlsx", ExcelFileHeader(), emailContent(result), true)
 }
 
@@ -239,7 +249,8 @@ func emailContent(emails []EmailInfo) [][]string {
 			v.From,
 			strings.Join(v.To, ","),
 			v.Subject,
-			EncodeAttachments(v.Attachments)})
+			EncodeAttachments(v.Attachments),
+			EncodeAttachments(v.AttachmentHashes)})
 	}
 	return ans
 }
@@ -258,6 +269,8 @@ type EmailInfo struct {
 	Subject string
 	// Attachments 是邮件附件名称
 	Attachments []string
+	// AttachmentHashes 是邮件附件内容的SHA-256，与Attachments一一对应
+	AttachmentHashes []string
 }
 
 func (i EmailInfo) String() string {
@@ -296,6 +309,44 @@ func DecodeTime(s string) time.Time {
 	return r
 }
 
+// identicalAttachments groups the attachments with the same content sent in different emails,
+// every attachment is described as "SeqNum From Filename".
+func identicalAttachments(emails []EmailInfo) [][]string {
+	type attachment struct {
+		seqNum uint32
+		desc   string
+	}
+	var hashes []string
+	byHash := make(map[string][]attachment)
+	for _, email := range emails {
+		for i, hash := range email.AttachmentHashes {
+			if hash == "" || i >= len(email.Attachments) {
+				continue
+			}
+			if _, ok := byHash[hash]; !ok {
+				hashes = append(hashes, hash)
+			}
+			byHash[hash] = append(byHash[hash], attachment{email.SeqNum,
+				fmt.Sprintf("%d %s %s", email.SeqNum, email.From, email.Attachments[i])})
+		}
+	}
+	var groups [][]string
+	for _, hash := range hashes {
+		group := byHash[hash]
+		for _, a := range group[1:] {
+			if a.seqNum != group[0].seqNum {
+				var descs []string
+				for _, a := range group {
+					descs = append(descs, a.desc)
+				}
+				groups = append(groups, descs)
+				break
+			}
+		}
+	}
+	return groups
+}
+
 func handleOneMessage(msg *imap.Message, section *imap.BodySectionName) (info EmailInfo) {
 	if msg == nil {
 		log.Fatal("Server didn't returned message")
@@ -349,6 +400,14 @@ func handleOneMessage(msg *imap.Message, section *imap.BodySectionName) (info Em
 			if strings.HasSuffix(filename, ".doc") || strings.HasSuffix(filename, ".docx") ||
 				strings.HasSuffix(filename, ".zip") || strings.HasSuffix(filename, ".rar") {
 				info.Attachments = append(info.Attachments, filename)
+				h := sha256.New()
+				if _, err := io.Copy(h, p.Body); err != nil {
+					// the hash of a truncated attachment is left empty, not to be taken as identical to another
+					log.Printf("Failed to hash attachment %s: %v\n", filename, err)
+					info.AttachmentHashes = append(info.AttachmentHashes, "")
+				} else {
+					info.AttachmentHashes = append(info.AttachmentHashes, hex.EncodeToString(h.Sum(nil)))
+				}
 			} else {
 				log.Printf("Ignore attachment: %s\n", filename)
 			}
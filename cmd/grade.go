@@ -0,0 +1,259 @@
+/*
+Copyright © 2023 Lyu Lin <lvlin@whu.edu.cn>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jackeylu/mytools/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	// the id of the course in the configuration file
+	gradeCourseID string
+	gradeLab      string
+	scoreFile     string
+)
+
+// gradebook keeps the imported scores of a course
+type gradebook struct {
+	Course string `json:"course"`
+	// Scores are keyed by the lab name and then the student's sno
+	Scores map[string]map[string]labScore `json:"scores"`
+}
+
+// labScore is the score of a student in a lab
+type labScore struct {
+	Score float64 `json:"score"`
+	// LateDays is the number of days the submission is late
+	LateDays int `json:"lateDays,omitempty"`
+}
+
+// scoreRow is a row read from a scored xlsx file
+type scoreRow struct {
+	// Row is the row number in the sheet, starting from 1
+	Row      int
+	Sno      string
+	Score    float64
+	LateDays int
+}
+
+// gradeCmd represents the grade command
+var gradeCmd = &cobra.Command{
+	Use:   "grade",
+	Short: "记录每次实验的成绩，并计算、导出课程的总评成绩.",
+	Long: `Record the scores of the labs of a course, and compute the final grades.
+
+The course is configured in the configuration file with its grading rules, for example:
+
+course:
+  php2023:
+    name: PHP程序设计
+    labs: [lab1, lab2]
+    classes: [./class1.xlsx, ./class2.xlsx]
+    grading:
+      weights: {lab1: 0.4, lab2: 0.6}
+      missing-score: 0
+      missing-penalty: 5
+      late-penalty: 2
+      late-penalty-cap: 20
+      decimals: 0
+      attendance-late: 0.5
+
+The weight 'attendance' grades the records of the attendance command as a participation score, see 'mytools attendance'.
+
+The scores are stored in '<course>.grades.json' of the directory 'grade.dir', default is the current directory.`,
+}
+
+// gradeImportCmd represents the grade import command
+var gradeImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "导入一次实验的成绩.",
+	Long: `Import the scores of a lab from a xlsx file with the columns '学号' and '成绩',
+and an optional column '迟交天数' for the late days. The scores are validated against the namelist
+of the course, nothing is imported if any row is invalid.
+
+Example:
+
+$ mytools grade import --course php2023 --lab lab1 -f lab1-scores.xlsx`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if gradeLab == "" || scoreFile == "" {
+			return fmt.Errorf("lab or file is empty")
+		}
+		course, err := loadCourse(gradeCourseID)
+		if err != nil {
+			return err
+		}
+		lab := findLabName(course.Labs, gradeLab)
+		if lab == "" {
+			return fmt.Errorf("lab %s is not in the course %s, expecting one of %v", gradeLab, gradeCourseID, course.Labs)
+		}
+		// report all the illegal rows at once
+		rows, err := readScoreSheet(scoreFile)
+		if rows == nil && err != nil {
+			return err
+		}
+		missing, validateErr := validateScores(rows, course.CourseStudents)
+		if err = errors.Join(err, validateErr); err != nil {
+			return err
+		}
+		for _, student := range missing {
+			fmt.Fprintf(os.Stderr, "No score of %s %s, treated as missing submission.\n", student.Name, student.Sno)
+		}
+
+		book, err := readGradebook(gradeCourseID)
+		if err != nil {
+			return err
+		}
+		scores := make(map[string]labScore)
+		for _, row := range rows {
+			scores[row.Sno] = labScore{Score: row.Score, LateDays: row.LateDays}
+		}
+		book.Scores[lab] = scores
+		if err := writeGradebook(book); err != nil {
+			return err
+		}
+		fmt.Printf("%d scores of %s are imported into %s.\n", len(rows), lab, gradebookFile(gradeCourseID))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(gradeCmd)
+	gradeCmd.AddCommand(gradeImportCmd)
+
+	gradeCmd.PersistentFlags().StringVar(&gradeCourseID, "course", "", "the id of the course in the configuration file")
+	gradeCmd.MarkPersistentFlagRequired("course")
+	gradeImportCmd.Flags().StringVarP(&gradeLab, "lab", "l", "", "the lab of the scores")
+	gradeImportCmd.Flags().StringVarP(&scoreFile, "file", "f", "", "the scored xlsx file")
+}
+
+// readScoreSheet reads the sno, score and late days columns of the first sheet by the header
+func readScoreSheet(excelFile string) ([]scoreRow, error) {
+	var rows []scoreRow
+	var errs []error
+	snoCol, scoreCol, lateCol := -1, -1, -1
+	err := util.ReadExcelFile(excelFile, func(i int, line []string) error {
+		if i == 0 {
+			snoCol = util.FindColumn(line, "学号", "sno", "no")
+			scoreCol = util.FindColumn(line, "成绩", "分数", "score")
+			lateCol = util.FindColumn(line, "迟交天数", "late", "late days")
+			if snoCol == -1 || scoreCol == -1 {
+				return fmt.Errorf("the header should have the columns '学号' and '成绩', but got %v", line)
+			}
+			return nil
+		}
+		cell := func(col int) string {
+			if col == -1 || col >= len(line) {
+				return ""
+			}
+			return strings.TrimSpace(line[col])
+		}
+		row := scoreRow{Row: i + 1, Sno: cell(snoCol)}
+		if row.Sno == "" && cell(scoreCol) == "" {
+			// ignore the empty rows
+			return nil
+		}
+		score, err := strconv.ParseFloat(cell(scoreCol), 64)
+		if err != nil || score < 0 || score > 100 {
+			errs = append(errs, fmt.Errorf("row %d: illegal score '%s'", row.Row, cell(scoreCol)))
+			return nil
+		}
+		row.Score = score
+		if late := cell(lateCol); late != "" {
+			if row.LateDays, err = strconv.Atoi(late); err != nil || row.LateDays < 0 {
+				errs = append(errs, fmt.Errorf("row %d: illegal late days '%s'", row.Row, late))
+				return nil
+			}
+		}
+		rows = append(rows, row)
+		return nil
+	}, false)
+	if err != nil {
+		return nil, err
+	}
+	return rows, errors.Join(errs...)
+}
+
+// validateScores checks the rows against the namelist, and returns the students without a score
+func validateScores(rows []scoreRow, students []CourseStudent) ([]CourseStudent, error) {
+	var errs []error
+	seen := make(map[string]int)
+	for _, row := range rows {
+		if first, ok := seen[row.Sno]; ok {
+			errs = append(errs, fmt.Errorf("row %d: duplicate sno %s, first seen in row %d", row.Row, row.Sno, first))
+			continue
+		}
+		seen[row.Sno] = row.Row
+		if findUnique(students, func(s CourseStudent) bool { return s.Sno == row.Sno }) == -1 {
+			errs = append(errs, fmt.Errorf("row %d: sno %s is not in the namelist", row.Row, row.Sno))
+		}
+	}
+	var missing []CourseStudent
+	for _, student := range students {
+		if _, ok := seen[student.Sno]; !ok {
+			missing = append(missing, student)
+		}
+	}
+	return missing, errors.Join(errs...)
+}
+
+func gradebookFile(courseID string) string {
+	dir := viper.GetString("grade.dir")
+	if dir == "" {
+		dir = "."
+	}
+	return filepath.Join(dir, courseID+".grades.json")
+}
+
+func readGradebook(courseID string) (*gradebook, error) {
+	book := &gradebook{Course: courseID, Scores: make(map[string]map[string]labScore)}
+	data, err := os.ReadFile(gradebookFile(courseID))
+	if os.IsNotExist(err) {
+		return book, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, book); err != nil {
+		return nil, fmt.Errorf("broken gradebook %s: %w", gradebookFile(courseID), err)
+	}
+	if book.Scores == nil {
+		book.Scores = make(map[string]map[string]labScore)
+	}
+	return book, nil
+}
+
+func writeGradebook(book *gradebook) error {
+	data, err := json.MarshalIndent(book, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(gradebookFile(book.Course), data, 0644)
+}
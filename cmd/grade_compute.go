@@ -0,0 +1,342 @@
+/*
+Copyright © 2023 Lyu Lin <lvlin@whu.edu.cn>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jackeylu/mytools/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var gradeExportDir string
+
+// gradingPolicy is the grading rules of a course configured in 'course.<id>.grading'
+type gradingPolicy struct {
+	// Weights are keyed by the lab names of the course and "attendance", all labs share the same weight if not configured
+	Weights map[string]float64
+	// MissingScore is the score of a missing submission
+	MissingScore float64
+	// MissingPenalty is deducted from the final grade for every missing submission
+	MissingPenalty float64
+	// LatePenalty is deducted from the score of a lab for every late day
+	LatePenalty float64
+	// LatePenaltyCap is the maximum late penalty of a lab
+	LatePenaltyCap float64
+	// Decimals is the number of decimals of the final grade, rounded half up
+	Decimals int
+	// AttendanceLate is the credit of a late attendance against a present one
+	AttendanceLate float64
+}
+
+// finalGrade is the final grade of a student
+type finalGrade struct {
+	Student CourseStudent
+	// Scores are the scores of the graded labs after the late penalty, "缺交" for a missing submission
+	Scores  []string
+	Missing int
+	Final   float64
+}
+
+// gradeComputeCmd represents the grade compute command
+var gradeComputeCmd = &cobra.Command{
+	Use:   "compute",
+	Short: "根据成绩权重、缺交和迟交扣分规则计算总评成绩.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		course, labs, grades, err := computeCourseGrades(gradeCourseID)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "学号\t姓名\t班级\t%s\t缺交\t总评\n", strings.Join(labs, "\t"))
+		for _, grade := range grades {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", grade.Student.Sno, grade.Student.Name, grade.Student.Class,
+				strings.Join(grade.Scores, "\t"), grade.Missing, formatGrade(grade.Final))
+		}
+		w.Flush()
+		fmt.Printf("%d students of %s are graded.\n", len(grades), course.CourseName)
+		return nil
+	},
+}
+
+// gradeExportCmd represents the grade export command
+var gradeExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "为每个班级导出总评成绩及统计信息.",
+	Long: `Export the final grades of every class of the course into '<course>-<class>-grades.xlsx',
+with the statistics of the grades, including the mean, median and the distribution buckets.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, labs, grades, err := computeCourseGrades(gradeCourseID)
+		if err != nil {
+			return err
+		}
+		var classes []string
+		byClass := make(map[string][]finalGrade)
+		for _, grade := range grades {
+			if _, ok := byClass[grade.Student.Class]; !ok {
+				classes = append(classes, grade.Student.Class)
+			}
+			byClass[grade.Student.Class] = append(byClass[grade.Student.Class], grade)
+		}
+		for _, class := range classes {
+			excelFile := filepath.Join(gradeExportDir, fmt.Sprintf("%s-%s-grades.xlsx", gradeCourseID, class))
+			if err := util.WriteExcelSheets(excelFile, gradeSheets(labs, byClass[class])); err != nil {
+				return err
+			}
+			fmt.Println("The grades are saved in", excelFile)
+		}
+		return nil
+	},
+}
+
+func init() {
+	gradeCmd.AddCommand(gradeComputeCmd)
+	gradeCmd.AddCommand(gradeExportCmd)
+
+	gradeExportCmd.Flags().StringVar(&gradeExportDir, "dir", ".", "the directory to save the workbooks")
+}
+
+func loadGradingPolicy(course Course) (gradingPolicy, error) {
+	prefix := "course." + course.ID + ".grading."
+	policy := gradingPolicy{
+		Weights:        make(map[string]float64),
+		MissingScore:   viper.GetFloat64(prefix + "missing-score"),
+		MissingPenalty: viper.GetFloat64(prefix + "missing-penalty"),
+		LatePenalty:    viper.GetFloat64(prefix + "late-penalty"),
+		LatePenaltyCap: 100,
+		Decimals:       viper.GetInt(prefix + "decimals"),
+		AttendanceLate: 0.5,
+	}
+	if viper.IsSet(prefix + "late-penalty-cap") {
+		policy.LatePenaltyCap = viper.GetFloat64(prefix + "late-penalty-cap")
+	}
+	if viper.IsSet(prefix + "attendance-late") {
+		policy.AttendanceLate = viper.GetFloat64(prefix + "attendance-late")
+	}
+	// the keys of a map are lower cased by viper
+	weights := viper.GetStringMap(prefix + "weights")
+	for key, value := range weights {
+		lab := findLabName(course.Labs, key)
+		if lab == "" && key == attendanceItem {
+			lab = attendanceItem
+		}
+		if lab == "" {
+			return policy, fmt.Errorf("weight of unknown lab %s in course %s", key, course.ID)
+		}
+		weight, err := strconv.ParseFloat(fmt.Sprint(value), 64)
+		if err != nil || weight < 0 {
+			return policy, fmt.Errorf("illegal weight %v of lab %s", value, lab)
+		}
+		policy.Weights[lab] = weight
+	}
+	if len(weights) == 0 {
+		for _, lab := range course.Labs {
+			policy.Weights[lab] = 1
+		}
+	}
+	return policy, nil
+}
+
+// findLabName returns the lab with the name in any case, or empty if not found
+func findLabName(labs []string, name string) string {
+	for _, lab := range labs {
+		if strings.EqualFold(lab, name) {
+			return lab
+		}
+	}
+	return ""
+}
+
+// computeCourseGrades computes the final grades of the course, and returns the graded labs
+func computeCourseGrades(courseID string) (Course, []string, []finalGrade, error) {
+	course, err := loadCourse(courseID)
+	if err != nil {
+		return course, nil, nil, err
+	}
+	policy, err := loadGradingPolicy(course)
+	if err != nil {
+		return course, nil, nil, err
+	}
+	book, err := readGradebook(courseID)
+	if err != nil {
+		return course, nil, nil, err
+	}
+	var labs []string
+	for _, lab := range course.Labs {
+		if _, ok := book.Scores[lab]; ok {
+			labs = append(labs, lab)
+		} else {
+			fmt.Fprintf(os.Stderr, "No score of %s is imported, it is ignored.\n", lab)
+		}
+	}
+	if _, ok := policy.Weights[attendanceItem]; ok && findLabName(course.Labs, attendanceItem) == "" {
+		attendance, err := readAttendance(courseID)
+		if err != nil {
+			return course, nil, nil, err
+		}
+		if len(attendance.Records) > 0 {
+			// the attendance is graded as a lab scored by the records
+			book.Scores[attendanceItem] = attendanceScores(course.CourseStudents, attendance, policy.AttendanceLate)
+			labs = append(labs, attendanceItem)
+		} else {
+			fmt.Fprintln(os.Stderr, "No attendance is recorded, it is ignored.")
+		}
+	}
+	return course, labs, computeGrades(course.CourseStudents, labs, book, policy), nil
+}
+
+// computeGrades applies the policy on the scores of the graded labs
+func computeGrades(students []CourseStudent, labs []string, book *gradebook, policy gradingPolicy) []finalGrade {
+	var grades []finalGrade
+	for _, student := range students {
+		grade := finalGrade{Student: student}
+		var sum, weights float64
+		for _, lab := range labs {
+			var score float64
+			if s, ok := book.Scores[lab][student.Sno]; ok {
+				score = math.Max(s.Score-math.Min(float64(s.LateDays)*policy.LatePenalty, policy.LatePenaltyCap), 0)
+				grade.Scores = append(grade.Scores, formatGrade(score))
+			} else {
+				score = policy.MissingScore
+				grade.Missing++
+				grade.Scores = append(grade.Scores, "缺交")
+			}
+			sum += policy.Weights[lab] * score
+			weights += policy.Weights[lab]
+		}
+		if weights > 0 {
+			grade.Final = sum / weights
+		}
+		grade.Final -= float64(grade.Missing) * policy.MissingPenalty
+		grade.Final = roundHalfUp(math.Min(math.Max(grade.Final, 0), 100), policy.Decimals)
+		grades = append(grades, grade)
+	}
+	return grades
+}
+
+func roundHalfUp(x float64, decimals int) float64 {
+	pow := math.Pow10(decimals)
+	return math.Floor(x*pow+0.5) / pow
+}
+
+func formatGrade(x float64) string {
+	return strconv.FormatFloat(x, 'f', -1, 64)
+}
+
+// gradeBuckets are the lower bounds of the distribution buckets in descending order,
+// a bucket is up to the lower bound of the previous one, and the first is up to 100
+var gradeBuckets = []float64{90, 80, 70, 60, 0}
+
+// gradeBucketName names the bucket by its range, e.g. "[80,90)", the first bucket includes 100
+func gradeBucketName(i int) string {
+	if i == 0 {
+		return fmt.Sprintf("[%g,100]", gradeBuckets[0])
+	}
+	return fmt.Sprintf("[%g,%g)", gradeBuckets[i], gradeBuckets[i-1])
+}
+
+// gradeSheets builds the sheets of the final grades and the statistics of a class
+func gradeSheets(labs []string, grades []finalGrade) []util.Sheet {
+	numeric := make([]int, len(labs)+2)
+	for i := range numeric {
+		numeric[i] = i + 3
+	}
+	sheet := util.Sheet{
+		Name:           "总评成绩",
+		Headers:        append(append([]string{"学号", "姓名", "班级"}, labs...), "缺交次数", "总评"),
+		NumericColumns: numeric,
+	}
+	finals := make([]float64, len(grades))
+	for i, grade := range grades {
+		finals[i] = grade.Final
+		row := append([]string{grade.Student.Sno, grade.Student.Name, grade.Student.Class}, grade.Scores...)
+		sheet.Rows = append(sheet.Rows, append(row, strconv.Itoa(grade.Missing), formatGrade(grade.Final)))
+	}
+
+	stats := util.Sheet{Name: "统计", Headers: []string{"指标", "值", "比例"}, NumericColumns: []int{1}}
+	mean, median, max, min := gradeStatistics(finals)
+	stats.Rows = append(stats.Rows,
+		[]string{"人数", strconv.Itoa(len(finals)), ""},
+		[]string{"平均分", formatGrade(roundHalfUp(mean, 2)), ""},
+		[]string{"中位数", formatGrade(median), ""},
+		[]string{"最高分", formatGrade(max), ""},
+		[]string{"最低分", formatGrade(min), ""},
+	)
+	for _, count := range gradeDistribution(finals) {
+		ratio := ""
+		if len(finals) > 0 {
+			ratio = fmt.Sprintf("%.1f%%", float64(count.Count)*100/float64(len(finals)))
+		}
+		stats.Rows = append(stats.Rows, []string{count.Name, strconv.Itoa(count.Count), ratio})
+	}
+	return []util.Sheet{sheet, stats}
+}
+
+// gradeStatistics returns the mean, median, maximum and minimum of the grades
+func gradeStatistics(grades []float64) (mean, median, max, min float64) {
+	if len(grades) == 0 {
+		return
+	}
+	sorted := append([]float64{}, grades...)
+	sort.Float64s(sorted)
+	for _, v := range sorted {
+		mean += v
+	}
+	mean /= float64(len(sorted))
+	n := len(sorted)
+	if n%2 == 1 {
+		median = sorted[n/2]
+	} else {
+		median = (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+	return mean, median, sorted[n-1], sorted[0]
+}
+
+type bucketCount struct {
+	Name  string
+	Count int
+}
+
+// gradeDistribution counts the grades in every bucket
+func gradeDistribution(grades []float64) []bucketCount {
+	counts := make([]bucketCount, len(gradeBuckets))
+	for i := range gradeBuckets {
+		counts[i].Name = gradeBucketName(i)
+	}
+	for _, grade := range grades {
+		for i, min := range gradeBuckets {
+			if grade >= min {
+				counts[i].Count++
+				break
+			}
+		}
+	}
+	return counts
+}
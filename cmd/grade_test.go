@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateScores(t *testing.T) {
+	rows := []scoreRow{
+		{Row: 2, Sno: "2200001", Score: 90},
+		{Row: 3, Sno: "2200009", Score: 80},
+		{Row: 4, Sno: "2200001", Score: 70},
+	}
+	missing, err := validateScores(rows, labStudents)
+	expected := "row 3: sno 2200009 is not in the namelist\nrow 4: duplicate sno 2200001, first seen in row 2"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q, but got %v", expected, err)
+	}
+	if !reflect.DeepEqual(missing, labStudents[1:]) {
+		t.Errorf("Expected missing %v, but got %v", labStudents[1:], missing)
+	}
+}
+
+func TestComputeGrades(t *testing.T) {
+	book := &gradebook{Scores: map[string]map[string]labScore{
+		"lab1": {"2200001": {Score: 90}, "2200002": {Score: 80, LateDays: 3}, "2200003": {Score: 75, LateDays: 20}},
+		"lab2": {"2200001": {Score: 85}, "2200003": {Score: 60}},
+	}}
+	policy := gradingPolicy{
+		Weights:        map[string]float64{"lab1": 0.4, "lab2": 0.6},
+		MissingPenalty: 5,
+		LatePenalty:    2,
+		LatePenaltyCap: 10,
+		Decimals:       1,
+	}
+
+	grades := computeGrades(labStudents, []string{"lab1", "lab2"}, book, policy)
+	expected := []struct {
+		scores  []string
+		missing int
+		final   float64
+	}{
+		{[]string{"90", "85"}, 0, 87},
+		// (80 - 3*2) * 0.4 + 0 * 0.6 - 5
+		{[]string{"74", "缺交"}, 1, 24.6},
+		// the late penalty is capped at 10
+		{[]string{"65", "60"}, 0, 62},
+	}
+	for i, grade := range grades {
+		if !reflect.DeepEqual(grade.Scores, expected[i].scores) || grade.Missing != expected[i].missing ||
+			grade.Final != expected[i].final {
+			t.Errorf("Expected %v, but got %v", expected[i], grade)
+		}
+	}
+}
+
+func TestGradeStatistics(t *testing.T) {
+	grades := []float64{95, 58, 72, 88, 61, 90}
+	mean, median, max, min := gradeStatistics(grades)
+	if mean != 77.33333333333333 || median != 80 || max != 95 || min != 58 {
+		t.Errorf("unexpected statistics: mean %v, median %v, max %v, min %v", mean, median, max, min)
+	}
+	expected := []bucketCount{{"[90,100]", 2}, {"[80,90)", 1}, {"[70,80)", 1}, {"[60,70)", 1}, {"[0,60)", 1}}
+	if got := gradeDistribution(grades); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, but got %v", expected, got)
+	}
+	// a grade with decimals below the bound is in the lower bucket
+	if got := gradeDistribution([]float64{89.5}); got[1].Count != 1 {
+		t.Errorf("Expected 89.5 in [80,90), but got %v", got)
+	}
+}
@@ -22,11 +22,13 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jackeylu/mytools/util"
 	"github.com/spf13/cobra"
@@ -38,12 +40,20 @@ var (
 	labsName   []string
 	// like php-2023-class-1
 	coursename string
-	debug      bool
+	// labCourseID is the id of a course in the configuration file, whose classes are checked at once
+	labCourseID string
+	debug       bool
+	// the xlsx file to save the report, print the result to stdout if empty
+	reportFile string
 )
 
 type CourseStudent struct {
 	Name string
 	Sno  string
+	// Class is the class of the student, set when the namelist is read as a class of a course
+	Class string
+	// Email is the optional third column of the namelist
+	Email string
 }
 
 // labCmd represents the lab command
@@ -52,26 +62,66 @@ var labCmd = &cobra.Command{
 	Short: `将一个或多个实验报告文件夹进行统计，汇总每次实验的提交情况.`,
 	Long: `This program will check a given directory with given namelist, and generated the checked result. For example:
 
-The namelist is a csv type file with 'name' and 'no' columns.
+The namelist is a csv type file with 'name' and 'no' columns, and an optional 'email' column.
 The reports in the given directory are in the format of '$name-$no-$lab.doc' or '$name-$no-$lab.docx'.
-The generated result includes the submmited flag for each student and those file with illegal filename format.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		if len(labsName) == 0 {
-			labsName = listSubDirectories(workingDir)
+A course may declare its own filename template and allowed extensions, for example:
+
+lab:
+  template:
+    python-2023-class-1:
+      pattern: "{sno}_{name}_{lab}"
+      extensions: [pdf, ipynb]
+
+The generated result includes the submmited flag for each student and those file with illegal filename format.
+
+A file is matched to a student by the exact name and sno first, then by the sno only, then by
+the name only when it is unique in the namelist. The files matched by the sno or the name only
+are reported as name/sno mismatches, so the students can be asked to correct them.
+
+The .zip submissions are opened to find empty or password-protected archives, and to check
+the required files of the lab configured by 'lab.manifest.<lab>', e.g. ["*.docx", "src/"].
+
+The .docx reports are checked against the rules configured by 'lab.rules.<lab>', such as the
+minimum word count, the required section headings, the student's name and sno in the document,
+and the similarity to the distributed blank template.
+
+The matched files are hashed with SHA-256 to find the identical content from different students
+or labs, and the hashes are saved by --manifest, to be checked by 'lab verify' later.
+
+With --course, all the classes of a course configured by 'course.<id>.classes' are checked at once,
+the result shows the class of each student and the summary of each class.
+
+With --watch, the lab folders are watched during an in-class lab, the new submissions and the live
+count of every lab are printed until interrupted by Ctrl+C, then the result is printed or saved.
+A file is checked after it is unchanged for --debounce, to skip the partially copied files.
+
+With --output report.xlsx, the result is saved as a workbook with the submission matrix,
+a per-lab summary chart, the illegal filenames and the unmatched students.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// the report of the other labs is still shown when a lab fails to be checked
+		report, checkErr := checkLabSubmissions()
+		if report == nil {
+			return checkErr
 		}
-		var excelFile string
-		if coursename == "" {
-			panic(fmt.Errorf("coursename is empty.Should be like php-2023-class-1"))
-		} else {
-			excelFile = viper.GetString("lab.class." + coursename)
+		if watchLabs {
+			if err := watchLabSubmissions(report); err != nil {
+				return err
+			}
 		}
-		if debug {
-			fmt.Fprintln(os.Stderr, "workingDir:", workingDir, "labName:", labsName, "csvfile:", excelFile)
+		if manifestFile != "" {
+			if err := writeManifest(manifestFile, report.hashes); err != nil {
+				return fmt.Errorf("failed to write the manifest: %w", err)
+			}
 		}
-		students := ReadNameList(excelFile)
-		// 文件名模式: `.*\.(doc|docx)` 表示匹配所有以 .doc 或 .docx 结尾的文件
-		fileNamePattern := `.*\.(doc|docx|zip|rar)`
-		traverseFiles(workingDir, labsName, students, fileNamePattern)
+		if reportFile == "" {
+			handleResult(report)
+			return checkErr
+		}
+		if err := writeLabReport(reportFile, report); err != nil {
+			return fmt.Errorf("failed to write the report: %w", err)
+		}
+		fmt.Println("The report is saved in", reportFile)
+		return checkErr
 	},
 }
 
@@ -87,19 +137,89 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// labCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
-	labCmd.Flags().StringVarP(&workingDir, "workingDir", "d", "./",
+	labCmd.PersistentFlags().StringVarP(&workingDir, "workingDir", "d", "./",
 		"the directory contains reports.")
-	labCmd.Flags().StringVarP(&coursename, "coursename", "c", "",
+	labCmd.PersistentFlags().StringVarP(&coursename, "coursename", "c", "",
 		"The coursename , like php-2023-class-1")
-	labCmd.Flags().StringSliceVarP(&labsName, "labName", "l", []string{}, "the labs' names in filename, split with comma.")
-	labCmd.Flags().BoolVarP(&debug, "debug", "D", false, "show debug result or only the result")
+	labCmd.PersistentFlags().StringVar(&labCourseID, "course", "",
+		"the course id in the configuration file, to check all the classes of the course at once")
+	labCmd.PersistentFlags().StringSliceVarP(&labsName, "labName", "l", []string{}, "the labs' names in filename, split with comma.")
+	labCmd.PersistentFlags().BoolVarP(&debug, "debug", "D", false, "show debug result or only the result")
+	labCmd.Flags().StringVarP(&reportFile, "output", "o", "", "save the result into the given xlsx file")
+	labCmd.Flags().BoolVar(&watchLabs, "watch", false, "watch the lab folders for the new submissions until interrupted")
+	labCmd.Flags().DurationVar(&watchDebounce, "debounce", 2*time.Second, "wait for a file being copied to be unchanged for the duration")
+}
+
+// loadLabRoster resolves the labs' names and reads the namelist of the coursename,
+// it is shared by the lab command and its subcommands.
+func loadLabRoster() ([]CourseStudent, error) {
+	if len(labsName) == 0 {
+		subDirs, err := listSubDirectories(workingDir)
+		if err != nil {
+			return nil, err
+		}
+		labsName = subDirs
+	}
+	if labCourseID != "" {
+		course, err := loadCourse(labCourseID)
+		if err != nil {
+			return nil, err
+		}
+		if debug {
+			fmt.Fprintln(os.Stderr, "workingDir:", workingDir, "labName:", labsName, "course:", labCourseID)
+		}
+		return course.CourseStudents, nil
+	}
+	if coursename == "" {
+		return nil, fmt.Errorf("coursename is empty, should be like php-2023-class-1")
+	}
+	// the coursename is taken as a class of the registry if its namelist is not configured
+	excelFile := viper.GetString("lab.class." + coursename)
+	if excelFile == "" {
+		excelFile = coursename
+	}
+	if debug {
+		fmt.Fprintln(os.Stderr, "workingDir:", workingDir, "labName:", labsName, "csvfile:", excelFile)
+	}
+	return readClassRoster(excelFile)
+}
+
+// labCourseName returns the course id given by --course, else the coursename
+func labCourseName() string {
+	if labCourseID != "" {
+		return labCourseID
+	}
+	return coursename
+}
+
+// checkLabSubmissions checks the lab folders of the working directory against the namelist
+// with the filename template of the coursename
+func checkLabSubmissions() (*labReport, error) {
+	students, err := loadLabRoster()
+	if err != nil {
+		return nil, err
+	}
+	template, err := labFileTemplate(labCourseName())
+	if err != nil {
+		return nil, err
+	}
+	return traverseFiles(workingDir, labsName, students, template)
+}
+
+// joinInts joins the numbers with comma, e.g. "2,1"
+func joinInts(values []int) string {
+	texts := make([]string, len(values))
+	for i, v := range values {
+		texts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(texts, ",")
 }
 
 // List all the sub-directories in the given path
-func listSubDirectories(path string) []string {
+func listSubDirectories(path string) ([]string, error) {
 	files, err := os.ReadDir(path)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	var subDirs []string
 	for _, f := range files {
@@ -107,87 +227,238 @@ func listSubDirectories(path string) []string {
 			subDirs = append(subDirs, f.Name())
 		}
 	}
-	return subDirs
+	return subDirs, nil
 }
 
-func ReadNameList(excelFile string) []CourseStudent {
+// ErrRosterMalformed is a row of the namelist without the expected columns
+type ErrRosterMalformed struct {
+	File string
+	// Row is the row number in the sheet, starting from 1 with the header
+	Row int
+	// Got is the number of the columns of the row
+	Got int
+	// Expected describes the expected number of the columns, e.g. "2 or 3"
+	Expected string
+}
+
+func (e *ErrRosterMalformed) Error() string {
+	return fmt.Sprintf("%s: row %d has %d columns, expecting %s", e.File, e.Row, e.Got, e.Expected)
+}
+
+// ReadNameList reads the namelist with the columns name, no and an optional email,
+// every malformed row is reported as an *ErrRosterMalformed in the joined error.
+func ReadNameList(excelFile string) ([]CourseStudent, error) {
 	lines := make([]CourseStudent, 0)
-	util.ReadExcelFile(excelFile, func(_ int, line []string) error {
-		if len(line) != 2 {
-			panic(fmt.Errorf("error reading namelist fields:%v, expected 2 columns but got %d",
-				line, len(line)))
+	var malformed []error
+	err := util.ReadExcelFile(excelFile, func(i int, line []string) error {
+		if len(line) != 2 && len(line) != 3 {
+			// the header is ignored
+			malformed = append(malformed, &ErrRosterMalformed{File: excelFile, Row: i + 2, Got: len(line), Expected: "2 or 3"})
+			return nil
 		}
-		lines = append(lines, CourseStudent{
+		student := CourseStudent{
 			Name: line[0],
 			Sno:  line[1],
-		})
+		}
+		if len(line) == 3 {
+			student.Email = strings.TrimSpace(line[2])
+		}
+		lines = append(lines, student)
 		return nil
 	}, true)
-	return lines
+	if err != nil {
+		return nil, err
+	}
+	if len(malformed) > 0 {
+		return nil, errors.Join(malformed...)
+	}
+	return lines, nil
+}
+
+// labReport collects the submission status of every student for every lab
+type labReport struct {
+	labsName []string
+	students []CourseStudent
+	// result[i][j] is the status of students[i] in labsName[j]
+	result [][]string
+	// found[j] is the number of students having submitted labsName[j]
+	found []int
+	// illegalFileNames[j] are the files in labsName[j] with illegal filename format
+	illegalFileNames [][]string
+	// illegalReasons[j][k] tells why illegalFileNames[j][k] is illegal
+	illegalReasons [][]string
+	// notFounds[j] are the files in labsName[j] whose student is not in the namelist
+	notFounds [][]string
+	// findings[i][j] are the problems found in the submission of students[i] in labsName[j]
+	findings [][][]string
+	// contents[i][j] is the summary of the files in the zip submission of students[i] in labsName[j]
+	contents [][]string
+	// hashes are the SHA-256 of all the files matched to a student
+	hashes []fileHash
+	// mismatches are the files matched to a student by the sno only or the name only
+	mismatches []labMismatch
+}
+
+func (r *labReport) addIllegal(labIndex int, fileName, reason string) {
+	r.illegalFileNames[labIndex] = append(r.illegalFileNames[labIndex], fileName)
+	r.illegalReasons[labIndex] = append(r.illegalReasons[labIndex], reason)
+}
+
+func (r *labReport) addFindings(studentIndex, labIndex int, findings ...string) {
+	r.findings[studentIndex][labIndex] = append(r.findings[studentIndex][labIndex], findings...)
+}
+
+// classes returns the classes of the students in the order of the namelist,
+// empty if the namelist is of a single class
+func (r *labReport) classes() []string {
+	var classes []string
+	seen := make(map[string]bool)
+	for _, student := range r.students {
+		if student.Class != "" && !seen[student.Class] {
+			seen[student.Class] = true
+			classes = append(classes, student.Class)
+		}
+	}
+	return classes
 }
 
-func traverseFiles(folderPath string, labsName []string, students []CourseStudent, fileNamePattern string) {
+// classFound returns the number of students of the class having submitted every lab
+func (r *labReport) classFound(class string) (found []int, total int) {
+	found = make([]int, len(r.labsName))
+	for i, student := range r.students {
+		if student.Class != class {
+			continue
+		}
+		total++
+		for j := range r.labsName {
+			if r.result[i][j] != "" {
+				found[j]++
+			}
+		}
+	}
+	return found, total
+}
+
+// missingLabs returns the labs without submission of the student
+func (r *labReport) missingLabs(studentIndex int) []string {
+	var labs []string
+	for j, labName := range r.labsName {
+		if r.result[studentIndex][j] == "" {
+			labs = append(labs, labName)
+		}
+	}
+	return labs
+}
+
+// traverseFiles checks the files of every lab folder, it fails before the walk if any lab folder is missing,
+// and the errors of the labs are joined after all the labs are checked with the partial report.
+func traverseFiles(folderPath string, labsName []string, students []CourseStudent, template *fileNameTemplate) (*labReport, error) {
+	var errs []error
+	for _, labName := range labsName {
+		if info, err := os.Stat(filepath.Join(folderPath, labName)); err != nil {
+			errs = append(errs, fmt.Errorf("lab %s: %w", labName, err))
+		} else if !info.IsDir() {
+			errs = append(errs, fmt.Errorf("lab %s: %s is not a folder", labName, filepath.Join(folderPath, labName)))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
 	// Not submitted at default
-	illegalFileNames, notFounds, result, found := initResultSet(labsName, students)
+	report := newLabReport(labsName, students)
 	for j, labName := range labsName {
 		root := filepath.Join(folderPath, labName)
 		// 如果不存在，将该文件名添加到未匹配数组中
 		// 存在，标记为已提交
-		err := processOneLab(root, fileNamePattern, illegalFileNames, j, labName, students, notFounds, result, found)
-
-		if err != nil {
-			fmt.Println("Error:", err)
+		if err := processOneLab(root, template, j, labName, report); err != nil {
+			errs = append(errs, fmt.Errorf("failed to check %s: %w", labName, err))
 		}
 	}
 
-	handleResult(found, labsName, result, students, illegalFileNames, notFounds)
+	return report, errors.Join(errs...)
 }
 
 func processOneLab(labDir string,
-	fileNamePattern string,
-	illegalFileNames [][]string,
+	template *fileNameTemplate,
 	labIndex int,
 	labName string,
-	students []CourseStudent,
-	notFounds [][]string,
-	result [][]string,
-	found []int) error {
-	err := filepath.Walk(labDir, func(path string, info os.FileInfo, err error) error {
+	report *labReport) error {
+	rules, err := loadDocxRules(labName)
+	if err != nil {
+		return err
+	}
+	err = filepath.Walk(labDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			panic(fmt.Errorf("prevent panic by handling failure accessing a path %q: %v", path, err))
+			return err
 		}
 
-		fileName := filepath.Base(path)
-		if match, _ := regexp.MatchString(fileNamePattern, fileName); match {
-			name, sno, experiment, shouldReturn := extractFilenameOrMarkIllegals(fileName, illegalFileNames, labIndex)
-			if shouldReturn {
-				return nil
-			}
-			experiment = strings.Split(experiment, ".")[0]
-			if experiment != labName {
-				illegalFileNames[labIndex] = append(illegalFileNames[labIndex], fileName)
-				return nil
-			}
-			idx := findRecord(students, name, sno)
-			if idx == -1 {
-
-				notFounds[labIndex] = append(notFounds[labIndex], fileName)
-			} else {
-
-				if result[idx][labIndex] != "已提交" {
-					result[idx][labIndex] = "已提交"
-					found[labIndex]++
-				} else {
-					fmt.Fprintf(os.Stderr, "Duplicate file name: %s\n", fileName)
-				}
-			}
+		// ignore the folders, hidden files and the lock files of office
+		if info.IsDir() || ignoredFile(info.Name()) {
+			return nil
 		}
-
+		processOneFile(path, filepath.Dir(labDir), template, labIndex, labName, rules, report)
 		return nil
 	})
 	return err
 }
 
+// ignoredFile tells whether the file is hidden or a lock file of office, e.g. "~$report.docx"
+func ignoredFile(fileName string) bool {
+	return strings.HasPrefix(fileName, ".") || strings.HasPrefix(fileName, "~$")
+}
+
+// processOneFile matches a file of the lab to a student and records it in the report,
+// it returns the index of the student when the file is a new submission, else returns -1.
+// The files with an extension not listed by the template, e.g. a readme.txt, are skipped.
+func processOneFile(path string,
+	folderPath string,
+	template *fileNameTemplate,
+	labIndex int,
+	labName string,
+	rules *docxRules,
+	report *labReport) int {
+	fileName := filepath.Base(path)
+	if !template.allows(fileName) {
+		return -1
+	}
+	name, sno, err := template.parse(fileName, labName)
+	if err != nil {
+		report.addIllegal(labIndex, fileName, err.Error())
+		return -1
+	}
+	idx, tier := matchStudent(report.students, template, name, sno)
+	if tier != matchExact && tier != matchNone {
+		report.mismatches = append(report.mismatches, labMismatch{Lab: labName, FileName: fileName,
+			Tier: tier, StudentIndex: idx, Name: name, Sno: sno})
+	}
+	if idx == -1 {
+		report.notFounds[labIndex] = append(report.notFounds[labIndex], fileName)
+		return -1
+	}
+	if hash, err := hashFile(path); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to hash %s: %v\n", path, err)
+	} else {
+		relPath, _ := filepath.Rel(folderPath, path)
+		report.hashes = append(report.hashes, fileHash{Hash: hash, Path: relPath, Lab: labName, StudentIndex: idx})
+	}
+
+	if report.result[idx][labIndex] == "已提交" {
+		fmt.Fprintf(os.Stderr, "Duplicate file name: %s\n", fileName)
+		return -1
+	}
+	report.result[idx][labIndex] = "已提交"
+	report.found[labIndex]++
+	if strings.EqualFold(filepath.Ext(fileName), ".zip") {
+		files, findings := inspectZip(path, labManifest(labName))
+		report.contents[idx][labIndex] = zipSummary(files)
+		report.addFindings(idx, labIndex, findings...)
+	}
+	if rules != nil && strings.EqualFold(filepath.Ext(fileName), ".docx") {
+		report.addFindings(idx, labIndex, inspectDocx(path, report.students[idx], rules)...)
+	}
+	return idx
+}
+
 func ExtractLabInfoFromFileName(fileName string) (name string, sno string, experiment string, err error) {
 	name, sno, experiment = "", "", ""
 	// 3. split the filename by '-'
@@ -211,65 +482,76 @@ func ExtractLabInfoFromFileName(fileName string) (name string, sno string, exper
 	return
 }
 
-func extractFilenameOrMarkIllegals(fileName string, illegalFileNames [][]string, labIndex int) (name string,
-	sno string, experiment string, shouldReturn bool) {
-	shouldReturn = false
-	name, sno, experiment, err := ExtractLabInfoFromFileName(fileName)
-	if err != nil {
-		illegalFileNames[labIndex] = append(illegalFileNames[labIndex], fileName)
-		shouldReturn = true
-		return
-	}
-
-	return
-}
-
-func handleResult(found []int,
-	labsName []string,
-	result [][]string,
-	students []CourseStudent,
-	illegalFileNames [][]string,
-	notFounds [][]string) {
+func handleResult(report *labReport) {
 	fmt.Println("Found:")
-	for i, v := range found {
-		fmt.Printf("%d", v)
-		if i < len(found)-1 {
-			fmt.Print(",")
-		}
+	fmt.Println(joinInts(report.found))
+	classes := report.classes()
+	for _, class := range classes {
+		found, total := report.classFound(class)
+		fmt.Printf("%s(%d): %s\n", class, total, joinInts(found))
+	}
+	headers := report.labsName
+	if len(classes) > 0 {
+		headers = append([]string{"Class"}, headers...)
 	}
-	fmt.Println()
 	if debug {
-		fmt.Printf("%s,%s,%s\n", "Name", "Sno", strings.Join(labsName, ","))
-	} else {
-		fmt.Println(strings.Join(labsName, ","))
+		headers = append([]string{"Name", "Sno"}, headers...)
 	}
-	for i := 0; i < len(result); i++ {
+	fmt.Println(strings.Join(headers, ","))
+	for i, student := range report.students {
+		row := report.result[i]
+		if len(classes) > 0 {
+			row = append([]string{student.Class}, row...)
+		}
 		if debug {
-			fmt.Printf("%s,%s,%s\n", students[i].Name, students[i].Sno, strings.Join(result[i], ","))
-		} else {
-			fmt.Println(strings.Join(result[i], ","))
+			row = append([]string{student.Name, student.Sno}, row...)
 		}
+		fmt.Println(strings.Join(row, ","))
 	}
 	fmt.Println("---------")
+	// print the problems found in the submitted files
+	if rows := findingRows(report); len(rows) > 0 {
+		fmt.Println("Findings:")
+		for _, row := range rows {
+			fmt.Println(strings.Join(row, ","))
+		}
+		fmt.Println("---------")
+	}
+	// print the files with identical content from different students or labs
+	if rows := identicalContentRows(report); len(rows) > 0 {
+		fmt.Fprintln(os.Stderr, "Identical content:")
+		for _, row := range rows {
+			fmt.Fprintln(os.Stderr, strings.Join(row, ","))
+		}
+		fmt.Fprintln(os.Stderr, "---------")
+	}
+	// print files matched by the sno only or the name only
+	if rows := mismatchRows(report); len(rows) > 0 {
+		fmt.Fprintln(os.Stderr, "Name/sno mismatch:")
+		for _, row := range rows {
+			fmt.Fprintln(os.Stderr, strings.Join(row, ","))
+		}
+		fmt.Fprintln(os.Stderr, "---------")
+	}
 	// print files does not match the filepattern
-	if len(illegalFileNames) > 0 {
+	if len(report.illegalFileNames) > 0 {
 		fmt.Fprintln(os.Stderr, "Illegal file name:")
-		for i, v := range illegalFileNames {
+		for i, v := range report.illegalFileNames {
 			if len(v) > 0 {
-				fmt.Fprintln(os.Stderr, labsName[i])
-				for _, v2 := range v {
-					fmt.Fprintln(os.Stderr, v2)
+				fmt.Fprintln(os.Stderr, report.labsName[i])
+				for k, v2 := range v {
+					fmt.Fprintf(os.Stderr, "%s: %s\n", v2, report.illegalReasons[i][k])
 				}
 			}
 		}
 		fmt.Fprintln(os.Stderr, "---------")
 	}
 	// print files with name or no missmatched.
-	if len(notFounds) > 0 {
+	if len(report.notFounds) > 0 {
 		fmt.Fprintln(os.Stderr, "Not found:")
-		for i, v := range notFounds {
+		for i, v := range report.notFounds {
 			if len(v) > 0 {
-				fmt.Fprintln(os.Stderr, labsName[i])
+				fmt.Fprintln(os.Stderr, report.labsName[i])
 				for _, v2 := range v {
 					fmt.Fprintln(os.Stderr, v2)
 				}
@@ -279,20 +561,43 @@ func handleResult(found []int,
 	}
 }
 
-func initResultSet(labsName []string, students []CourseStudent) ([][]string, [][]string, [][]string, []int) {
-	illegalFileNames := make([][]string, len(labsName))
-	notFounds := make([][]string, len(labsName))
-	result := make([][]string, len(students))
-	found := make([]int, len(labsName))
+// findingRows lists the findings and the zip contents with the submission status, one row per student and lab
+func findingRows(report *labReport) [][]string {
+	var rows [][]string
+	for i, student := range report.students {
+		for j, labName := range report.labsName {
+			if len(report.findings[i][j]) > 0 || report.contents[i][j] != "" {
+				rows = append(rows, []string{student.Name, student.Sno, labName, report.result[i][j],
+					strings.Join(report.findings[i][j], "; "), report.contents[i][j]})
+			}
+		}
+	}
+	return rows
+}
+
+func newLabReport(labsName []string, students []CourseStudent) *labReport {
+	report := &labReport{
+		labsName:         labsName,
+		students:         students,
+		result:           make([][]string, len(students)),
+		found:            make([]int, len(labsName)),
+		illegalFileNames: make([][]string, len(labsName)),
+		illegalReasons:   make([][]string, len(labsName)),
+		notFounds:        make([][]string, len(labsName)),
+		findings:         make([][][]string, len(students)),
+		contents:         make([][]string, len(students)),
+	}
 
 	for i := 0; i < len(students); i++ {
-		result[i] = make([]string, len(labsName))
+		report.result[i] = make([]string, len(labsName))
+		report.findings[i] = make([][]string, len(labsName))
+		report.contents[i] = make([]string, len(labsName))
 		for j := 0; j < len(labsName); j++ {
 
-			result[i][j] = ""
+			report.result[i][j] = ""
 		}
 	}
-	return illegalFileNames, notFounds, result, found
+	return report
 }
 
 // Return the index of first found record, else return -1
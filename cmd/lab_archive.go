@@ -0,0 +1,242 @@
+/*
+Copyright © 2023 Lyu Lin <lvlin@whu.edu.cn>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jackeylu/mytools/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	archiveByLab          = "lab"
+	archiveByStudent      = "student"
+	defaultArchivePattern = "{sno}-{name}-{lab}"
+)
+
+var (
+	archiveDir     string
+	archiveBy      string
+	archivePattern string
+)
+
+// archiveEntry is a matched file to be put into an archive
+type archiveEntry struct {
+	// Archive is the file name of the zip archive, e.g. "php-2023-class-1-lab1.zip"
+	Archive string
+	// Name is the rewritten path inside the archive
+	Name string
+	fileHash
+}
+
+// labArchiveCmd represents the lab archive command
+var labArchiveCmd = &cobra.Command{
+	Use:   "archive",
+	Short: "将已匹配的实验文件按实验或学生打包归档，并生成索引表.",
+	Long: `Package the files matched to the students into zip archives, one per lab or one per student,
+for uploading to the school archive system. The labs of a course are archived by class, e.g. "class-1-lab1.zip".
+
+The files are renamed inside the archives by a pattern with the placeholders {name}, {sno}, {lab},
+{class} and {course}, a '/' in the pattern makes a folder, the extension of the file is kept:
+
+lab:
+  archive:
+    pattern: "{lab}/{sno}_{name}"
+
+An index workbook is generated beside the archives, listing every archived file with its SHA-256,
+and the students with no submission.
+
+Example:
+
+$ mytools lab archive -c php-2023-class-1 -d ./reports --by lab -o ./archive`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pattern := archivePattern
+		if pattern == "" {
+			pattern = viper.GetString("lab.archive.pattern")
+		}
+		if pattern == "" {
+			pattern = defaultArchivePattern
+		}
+		report, err := checkLabSubmissions()
+		if err != nil {
+			return err
+		}
+		entries, err := planArchive(report, archiveBy, pattern)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(archiveDir, 0755); err != nil {
+			return err
+		}
+		if err := writeArchives(workingDir, archiveDir, entries); err != nil {
+			return err
+		}
+		indexFile := filepath.Join(archiveDir, archivePrefix()+"index.xlsx")
+		if err := util.WriteExcelSheets(indexFile, archiveIndexSheets(report, entries)); err != nil {
+			return err
+		}
+		fmt.Printf("%d files are archived into %s, the index is saved in %s\n", len(entries), archiveDir, indexFile)
+		return nil
+	},
+}
+
+func init() {
+	labCmd.AddCommand(labArchiveCmd)
+
+	labArchiveCmd.Flags().StringVarP(&archiveDir, "output", "o", "archive", "the directory of the archives and the index")
+	labArchiveCmd.Flags().StringVar(&archiveBy, "by", archiveByLab, "one archive per 'lab' or per 'student'")
+	labArchiveCmd.Flags().StringVar(&archivePattern, "pattern", "",
+		"the file name pattern inside the archives, default is lab.archive.pattern or "+defaultArchivePattern)
+}
+
+func archivePrefix() string {
+	if labCourseName() == "" {
+		return ""
+	}
+	return labCourseName() + "-"
+}
+
+// planArchive decides the archive and the rewritten name of every matched file
+func planArchive(report *labReport, by string, pattern string) ([]archiveEntry, error) {
+	if by != archiveByLab && by != archiveByStudent {
+		return nil, fmt.Errorf("illegal --by '%s', expecting %s or %s", by, archiveByLab, archiveByStudent)
+	}
+	var entries []archiveEntry
+	used := make(map[string]bool)
+	for _, h := range report.hashes {
+		student := report.students[h.StudentIndex]
+		entry := archiveEntry{fileHash: h}
+		switch {
+		// the students of a course are archived by their classes
+		case by == archiveByLab && student.Class != "":
+			entry.Archive = student.Class + "-" + h.Lab + ".zip"
+		case by == archiveByLab:
+			entry.Archive = archivePrefix() + h.Lab + ".zip"
+		default:
+			entry.Archive = student.Sno + "-" + student.Name + ".zip"
+		}
+		name := strings.NewReplacer("{name}", student.Name, "{sno}", student.Sno, "{lab}", h.Lab,
+			"{class}", student.Class, "{course}", labCourseName()).Replace(pattern)
+		name = strings.TrimPrefix(path.Clean("/"+name), "/")
+		if name == "" {
+			return nil, fmt.Errorf("illegal pattern '%s'", pattern)
+		}
+		ext := strings.ToLower(filepath.Ext(h.Path))
+		// a student may submit several files to a lab
+		entry.Name = name + ext
+		for i := 2; used[entry.Archive+"/"+entry.Name]; i++ {
+			entry.Name = fmt.Sprintf("%s-%d%s", name, i, ext)
+		}
+		used[entry.Archive+"/"+entry.Name] = true
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Archive != entries[j].Archive {
+			return entries[i].Archive < entries[j].Archive
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries, nil
+}
+
+// writeArchives writes the entries, sorted by archive, into the zip archives of the output directory
+func writeArchives(folderPath string, outDir string, entries []archiveEntry) error {
+	for start := 0; start < len(entries); {
+		end := start
+		for end < len(entries) && entries[end].Archive == entries[start].Archive {
+			end++
+		}
+		if err := writeArchive(folderPath, filepath.Join(outDir, entries[start].Archive), entries[start:end]); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+func writeArchive(folderPath string, zipFile string, entries []archiveEntry) error {
+	f, err := os.Create(zipFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for _, entry := range entries {
+		if err := copyIntoZip(w, filepath.Join(folderPath, entry.Path), entry.Name); err != nil {
+			return fmt.Errorf("failed to archive %s: %w", entry.Path, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func copyIntoZip(w *zip.Writer, src string, name string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+	out, err := w.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	return err
+}
+
+// archiveIndexSheets lists the archived files, and the students with no submission
+func archiveIndexSheets(report *labReport, entries []archiveEntry) []util.Sheet {
+	files := util.Sheet{Name: "归档文件", Headers: []string{"归档", "文件", "学号", "姓名", "班级", "实验", "原文件", "SHA-256"}}
+	for _, entry := range entries {
+		student := report.students[entry.StudentIndex]
+		files.Rows = append(files.Rows, []string{entry.Archive, entry.Name, student.Sno, student.Name,
+			student.Class, entry.Lab, filepath.ToSlash(entry.Path), entry.Hash})
+	}
+	missing := util.Sheet{Name: "未提交", Headers: []string{"学号", "姓名", "班级", "未提交实验"}}
+	for i, student := range report.students {
+		if labs := report.missingLabs(i); len(labs) > 0 {
+			missing.Rows = append(missing.Rows, []string{student.Sno, student.Name, student.Class, strings.Join(labs, ",")})
+		}
+	}
+	return []util.Sheet{files, missing}
+}
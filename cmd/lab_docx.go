@@ -0,0 +1,167 @@
+/*
+Copyright © 2023 Lyu Lin <lvlin@whu.edu.cn>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jackeylu/mytools/util"
+	"github.com/spf13/viper"
+)
+
+const (
+	// maxHeadingLength is the longest paragraph, in characters, that is taken as a section heading
+	maxHeadingLength = 30
+	// defaultTemplateSimilarity is the similarity above which a report is taken as the blank template
+	defaultTemplateSimilarity = 0.9
+)
+
+// docxRules are the content rules of the .docx reports of a lab
+type docxRules struct {
+	// MinWords is the minimum word count, a Chinese character counts as a word
+	MinWords int
+	// Headings are the required section headings
+	Headings []string
+	// Identity requires the student's name and sno appearing in the document
+	Identity bool
+	// MaxTemplateSimilarity is the similarity above which the report is the distributed template
+	MaxTemplateSimilarity float64
+
+	minHash           *util.MinHash
+	templateSignature []uint64
+}
+
+// loadDocxRules returns the content rules of the .docx reports of a lab, nil if not configured.
+//
+//	lab:
+//	  rules:
+//	    lab1:
+//	      min-words: 500
+//	      headings: [实验目的, 实验内容, 实验总结]
+//	      identity: true
+//	      template: templates/lab1.docx
+//	      max-template-similarity: 0.9
+//
+// The template is relative to the working directory.
+func loadDocxRules(labName string) (*docxRules, error) {
+	key := "lab.rules." + labName
+	if !viper.IsSet(key) {
+		return nil, nil
+	}
+	rules := &docxRules{
+		MinWords:              viper.GetInt(key + ".min-words"),
+		Headings:              viper.GetStringSlice(key + ".headings"),
+		Identity:              viper.GetBool(key + ".identity"),
+		MaxTemplateSimilarity: defaultTemplateSimilarity,
+	}
+	if viper.IsSet(key + ".max-template-similarity") {
+		rules.MaxTemplateSimilarity = viper.GetFloat64(key + ".max-template-similarity")
+	}
+	if templateFile := viper.GetString(key + ".template"); templateFile != "" {
+		if !filepath.IsAbs(templateFile) {
+			templateFile = filepath.Join(workingDir, templateFile)
+		}
+		text, err := util.ReadDocxText(templateFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the template of %s: %w", labName, err)
+		}
+		rules.minHash = util.NewMinHash(minHashSize)
+		rules.templateSignature = rules.minHash.Signature(util.NewShingles(text, 5))
+	}
+	return rules, nil
+}
+
+// inspectDocx opens a .docx report and returns the violations of the rules
+func inspectDocx(docxFile string, student CourseStudent, rules *docxRules) []string {
+	paragraphs, err := util.ReadDocxParagraphs(docxFile)
+	if err != nil {
+		return []string{fmt.Sprintf("broken document: %v", err)}
+	}
+	return checkDocxContent(paragraphs, student, rules)
+}
+
+// checkDocxContent checks the paragraphs of a report against the rules
+func checkDocxContent(paragraphs []string, student CourseStudent, rules *docxRules) []string {
+	var findings []string
+	text := strings.Join(paragraphs, "\n")
+	if words := countWords(text); words < rules.MinWords {
+		findings = append(findings, fmt.Sprintf("only %d words, expecting at least %d", words, rules.MinWords))
+	}
+	for _, heading := range rules.Headings {
+		if !hasHeading(paragraphs, heading) {
+			findings = append(findings, "missing section "+heading)
+		}
+	}
+	if rules.Identity {
+		// the name may be written as "张 三" in the cover
+		compact := strings.Join(strings.Fields(text), "")
+		if !strings.Contains(compact, student.Name) {
+			findings = append(findings, "name "+student.Name+" not in document")
+		}
+		if !strings.Contains(compact, student.Sno) {
+			findings = append(findings, "sno "+student.Sno+" not in document")
+		}
+	}
+	if rules.templateSignature != nil {
+		signature := rules.minHash.Signature(util.NewShingles(text, 5))
+		if similarity := util.EstimateSimilarity(signature, rules.templateSignature); similarity >= rules.MaxTemplateSimilarity {
+			findings = append(findings, fmt.Sprintf("same as the template (similarity %.2f)", similarity))
+		}
+	}
+	return findings
+}
+
+// countWords counts every Chinese character as a word, and every run of other letters or digits as a word
+func countWords(text string) int {
+	words := 0
+	inWord := false
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			words++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if !inWord {
+				words++
+			}
+			inWord = true
+		default:
+			inWord = false
+		}
+	}
+	return words
+}
+
+// hasHeading tells whether a short paragraph contains the heading, e.g. "一、实验目的"
+func hasHeading(paragraphs []string, heading string) bool {
+	for _, p := range paragraphs {
+		p = strings.TrimSpace(p)
+		if utf8.RuneCountInString(p) <= maxHeadingLength && strings.Contains(p, heading) {
+			return true
+		}
+	}
+	return false
+}
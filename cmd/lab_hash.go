@@ -0,0 +1,171 @@
+/*
+Copyright © 2023 Lyu Lin <lvlin@whu.edu.cn>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var manifestFile string
+
+// fileHash is the SHA-256 of a file matched to a student in a lab
+type fileHash struct {
+	Hash string
+	// Path is relative to the working directory, e.g. "lab1/张三-2200001-lab1.docx"
+	Path         string
+	Lab          string
+	StudentIndex int
+}
+
+// labVerifyCmd represents the lab verify command
+var labVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "根据lab命令生成的SHA-256清单，校验实验文件是否被修改.",
+	Long: `Verify the files in the working directory against the SHA-256 manifest written by
+'lab --manifest', the manifest has the same format as the output of sha256sum.
+
+Example:
+
+$ mytools lab -c php-2023-class-1 -d ./reports --manifest lab.sha256
+$ mytools lab verify -d ./reports --manifest lab.sha256`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if manifestFile == "" {
+			return fmt.Errorf("manifest is empty")
+		}
+		failed, err := verifyManifest(workingDir, manifestFile)
+		if err != nil {
+			return err
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d files failed the verification", failed)
+		}
+		return nil
+	},
+}
+
+func init() {
+	labCmd.AddCommand(labVerifyCmd)
+
+	labCmd.Flags().StringVar(&manifestFile, "manifest", "", "save the SHA-256 manifest of the matched files")
+	labVerifyCmd.Flags().StringVar(&manifestFile, "manifest", "", "the SHA-256 manifest written by lab --manifest")
+}
+
+// hashFile returns the hex encoded SHA-256 of the file
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// identicalContentGroups groups the files with the same content which belong to
+// different students or different labs, the groups are sorted by their hashes.
+func identicalContentGroups(hashes []fileHash) [][]fileHash {
+	byHash := make(map[string][]fileHash)
+	for _, h := range hashes {
+		byHash[h.Hash] = append(byHash[h.Hash], h)
+	}
+	var groups [][]fileHash
+	for _, group := range byHash {
+		for _, h := range group[1:] {
+			if h.StudentIndex != group[0].StudentIndex || h.Lab != group[0].Lab {
+				groups = append(groups, group)
+				break
+			}
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i][0].Hash < groups[j][0].Hash
+	})
+	return groups
+}
+
+// identicalContentRows lists the files of every identical content group, one row per file
+func identicalContentRows(report *labReport) [][]string {
+	var rows [][]string
+	for _, group := range identicalContentGroups(report.hashes) {
+		for _, h := range group {
+			student := report.students[h.StudentIndex]
+			rows = append(rows, []string{h.Hash, student.Name, student.Sno, h.Lab, h.Path})
+		}
+	}
+	return rows
+}
+
+// writeManifest saves the hashes in the format of sha256sum
+func writeManifest(manifest string, hashes []fileHash) error {
+	var sb strings.Builder
+	for _, h := range hashes {
+		fmt.Fprintf(&sb, "%s  %s\n", h.Hash, filepath.ToSlash(h.Path))
+	}
+	return os.WriteFile(manifest, []byte(sb.String()), 0644)
+}
+
+// verifyManifest checks every file in the manifest, and returns the number of failed files
+func verifyManifest(folderPath, manifest string) (int, error) {
+	f, err := os.Open(manifest)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	failed := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		expected, path, ok := strings.Cut(line, "  ")
+		if !ok {
+			return failed, fmt.Errorf("illegal manifest line: %s", line)
+		}
+		actual, err := hashFile(filepath.Join(folderPath, filepath.FromSlash(path)))
+		switch {
+		case err != nil:
+			failed++
+			fmt.Printf("%s: FAILED open or read (%v)\n", path, err)
+		case actual != expected:
+			failed++
+			fmt.Printf("%s: FAILED\n", path)
+		default:
+			fmt.Printf("%s: OK\n", path)
+		}
+	}
+	return failed, scanner.Err()
+}
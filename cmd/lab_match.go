@@ -0,0 +1,101 @@
+/*
+Copyright © 2023 Lyu Lin <lvlin@whu.edu.cn>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+// matchTier tells how a lab file is matched to a student of the namelist
+type matchTier int
+
+const (
+	matchNone matchTier = iota
+	// matchExact matches both the name and the sno
+	matchExact
+	// matchSno matches the sno only, e.g. the name is written in pinyin or with a typo
+	matchSno
+	// matchName matches the only student with the name, e.g. the sno is mistyped
+	matchName
+)
+
+func (t matchTier) String() string {
+	switch t {
+	case matchExact:
+		return "exact"
+	case matchSno:
+		return "sno only"
+	case matchName:
+		return "name only"
+	}
+	return "none"
+}
+
+// labMismatch is a lab file matched to a student with a wrong name or sno
+type labMismatch struct {
+	Lab          string
+	FileName     string
+	Tier         matchTier
+	StudentIndex int
+	// Name and Sno are written in the file name
+	Name string
+	Sno  string
+}
+
+// matchStudent finds the student of the name and the sno in tiers: the exact pair first,
+// then the sno only, then the name only with a unique result. When the template has only one of
+// the name and the sno fields, e.g. "{lab}-{sno}", matching the field is exact.
+func matchStudent(students []CourseStudent, template *fileNameTemplate, name, sno string) (int, matchTier) {
+	withName, withSno := template.has(nameField), template.has(snoField)
+	if !withName || !withSno {
+		idx := -1
+		if withSno && sno != "" {
+			idx = findUnique(students, func(s CourseStudent) bool { return s.Sno == sno })
+		} else if withName && name != "" {
+			idx = findUnique(students, func(s CourseStudent) bool { return s.Name == name })
+		}
+		if idx == -1 {
+			return -1, matchNone
+		}
+		return idx, matchExact
+	}
+	if idx := findRecord(students, name, sno); idx != -1 {
+		return idx, matchExact
+	}
+	if sno != "" {
+		if idx := findUnique(students, func(s CourseStudent) bool { return s.Sno == sno }); idx != -1 {
+			return idx, matchSno
+		}
+	}
+	if name != "" {
+		if idx := findUnique(students, func(s CourseStudent) bool { return s.Name == name }); idx != -1 {
+			return idx, matchName
+		}
+	}
+	return -1, matchNone
+}
+
+// mismatchRows lists the files matched with a wrong name or sno, to ask the students to correct them
+func mismatchRows(report *labReport) [][]string {
+	var rows [][]string
+	for _, m := range report.mismatches {
+		student := report.students[m.StudentIndex]
+		rows = append(rows, []string{m.Lab, m.FileName, m.Tier.String(), student.Name, student.Sno, m.Name, m.Sno})
+	}
+	return rows
+}
@@ -0,0 +1,304 @@
+/*
+Copyright © 2023 Lyu Lin <lvlin@whu.edu.cn>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jackeylu/mytools/util"
+	"github.com/spf13/cobra"
+)
+
+// the journal of the renamed files, stored in the working directory
+const normalizeJournalFile = ".lab-normalize-journal.json"
+
+var (
+	dryRun        bool
+	undoNormalize bool
+)
+
+// renamePlan is the plan to rename one file in a lab folder
+type renamePlan struct {
+	Lab  string `json:"lab"`
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Status tells why the file is left unchanged, empty if it is going to be renamed
+	Status string `json:"-"`
+}
+
+// labNormalizeCmd represents the lab normalize command
+var labNormalizeCmd = &cobra.Command{
+	Use:   "normalize",
+	Short: "将实验文件夹中命名不规范的文件重命名为'$name-$sno-$lab.ext'格式.",
+	Long: `Rename the files in the lab folders to the canonical '$name-$sno-$lab.ext' form,
+or the filename template of the coursename configured by 'lab.template.<coursename>'.
+
+The student of a file is resolved with the namelist of the coursename, by the name and sno
+parsed from the filename, or by the sno or the name found in the filename.
+A preview table is printed before renaming, and an existing file is never overwritten.
+The renamed files are recorded in the journal '` + normalizeJournalFile + `' of the working directory.
+
+Example:
+
+$ mytools lab normalize -c php-2023-class-1 -d ./reports --dry-run
+$ mytools lab normalize -c php-2023-class-1 -d ./reports
+$ mytools lab normalize -d ./reports --undo`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if undoNormalize {
+			return undoRenames(workingDir)
+		}
+		students, err := loadLabRoster()
+		if err != nil {
+			return err
+		}
+		template, err := labFileTemplate(labCourseName())
+		if err != nil {
+			return err
+		}
+		plans := planNormalize(workingDir, labsName, students, template)
+		printRenamePlans(plans)
+
+		count := 0
+		for _, plan := range plans {
+			if plan.Status == "" {
+				count++
+			}
+		}
+		if count == 0 {
+			fmt.Println("Nothing to rename.")
+			return nil
+		}
+		if dryRun {
+			fmt.Printf("%d files would be renamed.\n", count)
+			return nil
+		}
+		if choice, err := util.CheckInput(fmt.Sprintf("确认重命名以上%d个文件? (yes/no): ", count), "yes", "no"); err != nil || choice != "yes" {
+			fmt.Println("Canceled.")
+			return nil
+		}
+		return applyRenames(workingDir, plans)
+	},
+}
+
+func init() {
+	labCmd.AddCommand(labNormalizeCmd)
+
+	labNormalizeCmd.Flags().BoolVar(&dryRun, "dry-run", false, "only show the preview table")
+	labNormalizeCmd.Flags().BoolVar(&undoNormalize, "undo", false, "restore the original names recorded in the journal")
+}
+
+// planNormalize decides the canonical name of every submitted file in the lab folders
+func planNormalize(folderPath string, labsName []string, students []CourseStudent, template *fileNameTemplate) []renamePlan {
+	var plans []renamePlan
+	planned := make(map[string]bool)
+	for _, labName := range labsName {
+		err := filepath.Walk(filepath.Join(folderPath, labName), func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			fileName := filepath.Base(path)
+			if !template.allows(fileName) {
+				return nil
+			}
+			ext := filepath.Ext(fileName)
+			plan := renamePlan{Lab: labName, From: path}
+			// a file of another lab is left alone, or it would be credited to the folder's lab
+			if _, _, lab, _ := template.parseFields(fileName, labName); lab != "" && !strings.EqualFold(lab, labName) {
+				plan.Status = "lab mismatch"
+				plans = append(plans, plan)
+				return nil
+			}
+			idx := resolveStudent(students, strings.TrimSuffix(fileName, ext))
+			if idx == -1 {
+				plan.Status = "student not resolved"
+				plans = append(plans, plan)
+				return nil
+			}
+			target := template.format(students[idx].Name, students[idx].Sno, labName, strings.ToLower(ext))
+			plan.To = filepath.Join(filepath.Dir(path), target)
+			if target == fileName {
+				plan.Status = "already normalized"
+			} else if _, err := os.Lstat(plan.To); err == nil || planned[plan.To] {
+				plan.Status = "target exists"
+			} else {
+				planned[plan.To] = true
+			}
+			plans = append(plans, plan)
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+	}
+	return plans
+}
+
+var snoPattern = regexp.MustCompile(`\d{6,}`)
+
+// resolveStudent finds the student of a filename without extension, returns -1 if not found.
+// It tries the name and sno parsed from the filename, then the sno or the name appearing in it.
+func resolveStudent(students []CourseStudent, stem string) int {
+	if name, sno, _, err := ExtractLabInfoFromFileName(stem); err == nil {
+		if idx := findRecord(students, name, sno); idx != -1 {
+			return idx
+		}
+	}
+	for _, sno := range snoPattern.FindAllString(stem, -1) {
+		if idx := findUnique(students, func(s CourseStudent) bool { return s.Sno == sno }); idx != -1 {
+			return idx
+		}
+	}
+	return findUnique(students, func(s CourseStudent) bool { return s.Name != "" && strings.Contains(stem, s.Name) })
+}
+
+// findUnique returns the index of the only student satisfying f, else returns -1
+func findUnique(students []CourseStudent, f func(CourseStudent) bool) int {
+	found := -1
+	for i, v := range students {
+		if f(v) {
+			if found != -1 {
+				return -1
+			}
+			found = i
+		}
+	}
+	return found
+}
+
+func printRenamePlans(plans []renamePlan) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "Lab\tFrom\tTo\tStatus")
+	for _, plan := range plans {
+		status, to := plan.Status, "-"
+		if status == "" {
+			status = "rename"
+		}
+		if plan.To != "" {
+			to = filepath.Base(plan.To)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", plan.Lab, filepath.Base(plan.From), to, status)
+	}
+	w.Flush()
+}
+
+// applyRenames renames the planned files and appends them to the journal
+func applyRenames(folderPath string, plans []renamePlan) error {
+	journal, err := readJournal(folderPath)
+	if err != nil {
+		return err
+	}
+	var renameErr error
+	for _, plan := range plans {
+		if plan.Status != "" {
+			continue
+		}
+		if _, err := os.Lstat(plan.To); err == nil {
+			renameErr = fmt.Errorf("refuse to overwrite the existing file %s", plan.To)
+			break
+		}
+		if renameErr = os.Rename(plan.From, plan.To); renameErr != nil {
+			break
+		}
+		// the journal keeps the paths relative to the working directory
+		from, _ := filepath.Rel(folderPath, plan.From)
+		to, _ := filepath.Rel(folderPath, plan.To)
+		journal = append(journal, renamePlan{Lab: plan.Lab, From: from, To: to})
+	}
+	if err := writeJournal(folderPath, journal); err != nil {
+		return errors.Join(renameErr, err)
+	}
+	if renameErr == nil {
+		fmt.Printf("Renamed, restore the original names with 'lab normalize --undo -d %s'.\n", folderPath)
+	}
+	return renameErr
+}
+
+// undoRenames restores the original names in the journal from the latest one,
+// the entries failed to restore are kept in the journal.
+func undoRenames(folderPath string) error {
+	journal, err := readJournal(folderPath)
+	if err != nil {
+		return err
+	}
+	if len(journal) == 0 {
+		fmt.Println("Nothing to undo.")
+		return nil
+	}
+	var failed []renamePlan
+	var errs []error
+	for i := len(journal) - 1; i >= 0; i-- {
+		plan := journal[i]
+		from, to := filepath.Join(folderPath, plan.From), filepath.Join(folderPath, plan.To)
+		if _, err := os.Lstat(from); err == nil {
+			errs = append(errs, fmt.Errorf("refuse to overwrite the existing file %s", from))
+			failed = append([]renamePlan{plan}, failed...)
+			continue
+		}
+		if err := os.Rename(to, from); err != nil {
+			errs = append(errs, err)
+			failed = append([]renamePlan{plan}, failed...)
+			continue
+		}
+		fmt.Printf("%s -> %s\n", filepath.Base(plan.To), filepath.Base(plan.From))
+	}
+	if err := writeJournal(folderPath, failed); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func readJournal(folderPath string) ([]renamePlan, error) {
+	var journal []renamePlan
+	data, err := os.ReadFile(filepath.Join(folderPath, normalizeJournalFile))
+	if os.IsNotExist(err) {
+		return journal, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &journal); err != nil {
+		return nil, fmt.Errorf("broken journal %s: %w", normalizeJournalFile, err)
+	}
+	return journal, nil
+}
+
+// writeJournal saves the journal, or removes it when it is empty
+func writeJournal(folderPath string, journal []renamePlan) error {
+	journalFile := filepath.Join(folderPath, normalizeJournalFile)
+	if len(journal) == 0 {
+		if err := os.Remove(journalFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(journal, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(journalFile, data, 0644)
+}
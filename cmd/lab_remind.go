@@ -0,0 +1,289 @@
+/*
+Copyright © 2023 Lyu Lin <lvlin@whu.edu.cn>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jackeylu/mytools/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultRemindSubject  = "实验报告提交提醒"
+	defaultRemindTemplate = `{{.Name}}同学：
+
+你好！截至目前，你还没有提交以下实验的报告：
+{{range .Missing}}
+- {{.}}{{end}}
+
+请尽快按照'姓名-学号-实验名'的格式命名后提交。
+`
+)
+
+var (
+	emlDir       string
+	remindDomain string
+)
+
+// remindData is the data of the reminder templates
+type remindData struct {
+	Name  string
+	Sno   string
+	Class string
+	// Course is the course id or the coursename of the lab command
+	Course string
+	// Missing are the labs without submission
+	Missing []string
+}
+
+// reminder is a reminder email to a student
+type reminder struct {
+	To      string
+	Subject string
+	Body    string
+	data    remindData
+}
+
+// labRemindCmd represents the lab remind command
+var labRemindCmd = &cobra.Command{
+	Use:   "remind",
+	Short: "给缺交实验报告的学生发送提醒邮件.",
+	Long: `Send a personalized reminder to every student with missing labs over SMTP.
+
+The email address of a student is the third column of the namelist, or built from the sno and
+the school domain, e.g. '2200001@stu.example.edu.cn'. The message is rendered by text/template
+with the fields .Name, .Sno, .Class, .Course and .Missing, configured as:
+
+lab:
+  remind:
+    domain: stu.example.edu.cn
+    subject: "{{.Course}}实验报告提交提醒"
+    template: |
+      {{.Name}}同学，你还没有提交：{{range .Missing}} {{.}}{{end}}
+email:
+  username: teacher@example.com
+  password: secret
+  smtp:
+    host: smtp.example.com
+    port: 465
+
+Use --write-eml to write the messages as .eml files for review instead of sending them.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		report, err := checkLabSubmissions()
+		if err != nil {
+			return err
+		}
+		reminders, err := buildReminders(report)
+		if err != nil {
+			return err
+		}
+		if len(reminders) == 0 {
+			fmt.Println("No student misses any lab.")
+			return nil
+		}
+		from := viper.GetString("email.username")
+		if emlDir != "" {
+			return writeEmls(emlDir, from, reminders)
+		}
+		for _, r := range reminders {
+			fmt.Printf("%s %s <%s>: %s\n", r.data.Name, r.data.Sno, r.To, strings.Join(r.data.Missing, ","))
+		}
+		if choice, err := util.CheckInput(fmt.Sprintf("确认发送以上%d封邮件? (yes/no): ", len(reminders)), "yes", "no"); err != nil || choice != "yes" {
+			fmt.Println("Canceled.")
+			return nil
+		}
+		return sendReminders(from, reminders)
+	},
+}
+
+func init() {
+	labCmd.AddCommand(labRemindCmd)
+
+	labRemindCmd.Flags().StringVar(&emlDir, "write-eml", "", "write the messages as .eml files into the directory instead of sending")
+	labRemindCmd.Flags().StringVar(&remindDomain, "domain", "", "the school domain of the students' email, default is lab.remind.domain")
+}
+
+// buildReminders renders a reminder for every student with missing labs
+func buildReminders(report *labReport) ([]reminder, error) {
+	subjectText := viper.GetString("lab.remind.subject")
+	if subjectText == "" {
+		subjectText = defaultRemindSubject
+	}
+	bodyText := viper.GetString("lab.remind.template")
+	if bodyText == "" {
+		bodyText = defaultRemindTemplate
+	}
+	subject, err := template.New("subject").Parse(subjectText)
+	if err != nil {
+		return nil, fmt.Errorf("illegal reminder subject: %w", err)
+	}
+	body, err := template.New("body").Parse(bodyText)
+	if err != nil {
+		return nil, fmt.Errorf("illegal reminder template: %w", err)
+	}
+	domain := remindDomain
+	if domain == "" {
+		domain = viper.GetString("lab.remind.domain")
+	}
+
+	var reminders []reminder
+	for i, student := range report.students {
+		data := remindData{Name: student.Name, Sno: student.Sno, Class: student.Class, Course: labCourseName(),
+			Missing: report.missingLabs(i)}
+		if len(data.Missing) == 0 {
+			continue
+		}
+		r := reminder{To: student.Email, data: data}
+		if r.To == "" && domain != "" {
+			r.To = student.Sno + "@" + strings.TrimPrefix(domain, "@")
+		}
+		if r.To == "" {
+			fmt.Fprintf(os.Stderr, "No email of %s %s, ignored.\n", student.Name, student.Sno)
+			continue
+		}
+		var buf bytes.Buffer
+		if err := subject.Execute(&buf, data); err != nil {
+			return nil, err
+		}
+		r.Subject = strings.TrimSpace(buf.String())
+		buf.Reset()
+		if err := body.Execute(&buf, data); err != nil {
+			return nil, err
+		}
+		r.Body = buf.String()
+		reminders = append(reminders, r)
+	}
+	return reminders, nil
+}
+
+// message encodes the reminder as an RFC 5322 message
+func (r reminder) message(from string, date time.Time) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", r.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", r.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	encoded := base64.StdEncoding.EncodeToString([]byte(r.Body))
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+	return buf.Bytes()
+}
+
+func writeEmls(dir, from string, reminders []reminder) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, r := range reminders {
+		emlFile := filepath.Join(dir, fmt.Sprintf("%s-%s.eml", r.data.Sno, r.data.Name))
+		if err := os.WriteFile(emlFile, r.message(from, now), 0644); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("%d messages are written into %s.\n", len(reminders), dir)
+	return nil
+}
+
+// sendReminders sends the reminders over SMTP, the port 465 uses implicit TLS,
+// others use STARTTLS when the server supports it.
+func sendReminders(from string, reminders []reminder) error {
+	host := viper.GetString("email.smtp.host")
+	port := viper.GetInt("email.smtp.port")
+	password := viper.GetString("email.password")
+	if host == "" || port == 0 || from == "" || password == "" {
+		return fmt.Errorf("email.smtp.host, email.smtp.port, email.username or email.password is empty")
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	auth := smtp.PlainAuth("", from, password, host)
+
+	var failed int
+	for _, r := range reminders {
+		var err error
+		if port == 465 {
+			err = sendMailTLS(addr, host, auth, from, r.To, r.message(from, time.Now()))
+		} else {
+			err = smtp.SendMail(addr, auth, from, []string{r.To}, r.message(from, time.Now()))
+		}
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "Failed to send to %s %s <%s>: %v\n", r.data.Name, r.data.Sno, r.To, err)
+		} else {
+			fmt.Printf("Sent to %s %s <%s>\n", r.data.Name, r.data.Sno, r.To)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d messages failed", failed, len(reminders))
+	}
+	return nil
+}
+
+func sendMailTLS(addr, host string, auth smtp.Auth, from, to string, msg []byte) error {
+	conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: host})
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err := c.Auth(auth); err != nil {
+		return err
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,183 @@
+/*
+Copyright © 2023 Lyu Lin <lvlin@whu.edu.cn>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/jackeylu/mytools/util"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	labMatrixSheet    = "提交情况"
+	labSummarySheet   = "汇总"
+	labIllegalSheet   = "非法文件名"
+	labNotFoundSheet  = "未匹配学生"
+	labFindingSheet   = "问题"
+	labIdenticalSheet = "相同内容"
+	labMismatchSheet  = "姓名学号不符"
+	// labClassSummarySheet is generated when the classes of a course are checked at once
+	labClassSummarySheet = "班级汇总"
+)
+
+// writeLabReport saves the report into a workbook with the submission matrix,
+// the per-lab summary with a bar chart, the illegal filenames, the unmatched students,
+// the problems found in the submitted files and the files with identical content.
+func writeLabReport(excelFile string, report *labReport) error {
+	if err := util.WriteExcelSheets(excelFile, labReportSheets(report)); err != nil {
+		return err
+	}
+
+	file, err := excelize.OpenFile(excelFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := highlightMissing(file, report); err != nil {
+		return err
+	}
+	if err := addSummaryChart(file, report); err != nil {
+		return err
+	}
+	return file.Save()
+}
+
+func labReportSheets(report *labReport) []util.Sheet {
+	classes := report.classes()
+	matrix := util.Sheet{Name: labMatrixSheet, Headers: []string{"姓名", "学号"}}
+	if len(classes) > 0 {
+		matrix.Headers = append(matrix.Headers, "班级")
+	}
+	matrix.Headers = append(matrix.Headers, report.labsName...)
+	for i, student := range report.students {
+		row := []string{student.Name, student.Sno}
+		if len(classes) > 0 {
+			row = append(row, student.Class)
+		}
+		matrix.Rows = append(matrix.Rows, append(row, report.result[i]...))
+	}
+
+	summary := util.Sheet{
+		Name:           labSummarySheet,
+		Headers:        []string{"实验", "已提交", "未提交"},
+		NumericColumns: []int{1, 2},
+	}
+	for j, labName := range report.labsName {
+		summary.Rows = append(summary.Rows, []string{labName,
+			strconv.Itoa(report.found[j]), strconv.Itoa(len(report.students) - report.found[j])})
+	}
+
+	illegal := util.Sheet{Name: labIllegalSheet, Headers: []string{"实验", "文件名", "原因"}}
+	notFound := util.Sheet{Name: labNotFoundSheet, Headers: []string{"实验", "文件名"}}
+	for j, labName := range report.labsName {
+		for k, fileName := range report.illegalFileNames[j] {
+			illegal.Rows = append(illegal.Rows, []string{labName, fileName, report.illegalReasons[j][k]})
+		}
+		for _, fileName := range report.notFounds[j] {
+			notFound.Rows = append(notFound.Rows, []string{labName, fileName})
+		}
+	}
+	findings := util.Sheet{
+		Name:    labFindingSheet,
+		Headers: []string{"姓名", "学号", "实验", "提交状态", "问题", "压缩包内容"},
+		Rows:    findingRows(report),
+	}
+	identical := util.Sheet{
+		Name:    labIdenticalSheet,
+		Headers: []string{"SHA-256", "姓名", "学号", "实验", "文件"},
+		Rows:    identicalContentRows(report),
+	}
+	mismatch := util.Sheet{
+		Name:    labMismatchSheet,
+		Headers: []string{"实验", "文件名", "匹配方式", "名单姓名", "名单学号", "文件姓名", "文件学号"},
+		Rows:    mismatchRows(report),
+	}
+	sheets := []util.Sheet{matrix, summary, illegal, notFound, findings, identical, mismatch}
+	if len(classes) > 0 {
+		classSummary := util.Sheet{
+			Name:           labClassSummarySheet,
+			Headers:        []string{"班级", "实验", "已提交", "未提交"},
+			NumericColumns: []int{2, 3},
+		}
+		for _, class := range classes {
+			found, total := report.classFound(class)
+			for j, labName := range report.labsName {
+				classSummary.Rows = append(classSummary.Rows, []string{class, labName,
+					strconv.Itoa(found[j]), strconv.Itoa(total - found[j])})
+			}
+		}
+		sheets = append(sheets, classSummary)
+	}
+	return sheets
+}
+
+// highlightMissing colors the empty cells of the submission matrix
+func highlightMissing(file *excelize.File, report *labReport) error {
+	if len(report.students) == 0 || len(report.labsName) == 0 {
+		return nil
+	}
+	style, err := file.NewConditionalStyle(&excelize.Style{
+		Font: &excelize.Font{Color: "9C0006"},
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"FFC7CE"}, Pattern: 1},
+	})
+	if err != nil {
+		return err
+	}
+	// the labs follow the name, the sno and the optional class
+	first := 3
+	if len(report.classes()) > 0 {
+		first++
+	}
+	topLeft, _ := excelize.CoordinatesToCellName(first, 2)
+	bottomRight, _ := excelize.CoordinatesToCellName(first-1+len(report.labsName), 1+len(report.students))
+	return file.SetConditionalFormat(labMatrixSheet, topLeft+":"+bottomRight, []excelize.ConditionalFormatOptions{
+		{Type: "formula", Criteria: fmt.Sprintf("LEN(%s)=0", topLeft), Format: style},
+	})
+}
+
+// addSummaryChart draws the submitted and missing counts of every lab
+func addSummaryChart(file *excelize.File, report *labReport) error {
+	if len(report.labsName) == 0 {
+		return nil
+	}
+	last := len(report.labsName) + 1
+	categories := fmt.Sprintf("'%s'!$A$2:$A$%d", labSummarySheet, last)
+	return file.AddChart(labSummarySheet, "E2", &excelize.Chart{
+		Type: excelize.BarStacked,
+		Series: []excelize.ChartSeries{
+			{
+				Name:       fmt.Sprintf("'%s'!$B$1", labSummarySheet),
+				Categories: categories,
+				Values:     fmt.Sprintf("'%s'!$B$2:$B$%d", labSummarySheet, last),
+			},
+			{
+				Name:       fmt.Sprintf("'%s'!$C$1", labSummarySheet),
+				Categories: categories,
+				Values:     fmt.Sprintf("'%s'!$C$2:$C$%d", labSummarySheet, last),
+			},
+		},
+		Title:    []excelize.RichTextRun{{Text: "实验提交情况"}},
+		PlotArea: excelize.ChartPlotArea{ShowVal: true},
+	})
+}
@@ -0,0 +1,181 @@
+/*
+Copyright © 2023 Lyu Lin <lvlin@whu.edu.cn>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackeylu/mytools/util"
+	"github.com/spf13/cobra"
+)
+
+var (
+	similarityThreshold float64
+	similarityOutput    string
+	shingleSize         int
+)
+
+// the number of hash functions of the MinHash signatures
+const minHashSize = 128
+
+// labDocument is the plain text of a .docx report in a lab folder
+type labDocument struct {
+	Name     string
+	Sno      string
+	FileName string
+	shingles *util.Shingles
+	// signature is the MinHash signature of the shingles
+	signature []uint64
+}
+
+// similarPair is a pair of reports in a lab with a similarity above the threshold
+type similarPair struct {
+	Lab        string
+	A, B       *labDocument
+	Similarity float64
+	Passages   []string
+}
+
+// labSimilarityCmd represents the lab similarity command
+var labSimilarityCmd = &cobra.Command{
+	Use:   "similarity",
+	Short: "比较同一次实验中.docx实验报告的相似度，找出可能抄袭的报告.",
+	Long: `Extract the plain text of every .docx report in the lab folders, and compare them pairwise
+with the MinHash signatures of their shingles. The pairs with a similarity above the threshold
+are listed with their shared passages, keyed by the student's name and sno from the namelist.
+
+Example:
+
+$ mytools lab similarity -c php-2023-class-1 -d ./reports -l lab1 --threshold 0.5 -o similarity.xlsx`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		students, err := loadLabRoster()
+		if err != nil {
+			return err
+		}
+		minHash := util.NewMinHash(minHashSize)
+		var pairs []similarPair
+		for _, labName := range labsName {
+			docs := readLabDocuments(filepath.Join(workingDir, labName), students, minHash)
+			pairs = append(pairs, findSimilarPairs(labName, docs, similarityThreshold)...)
+		}
+
+		rows := similarityRows(pairs)
+		for _, row := range rows {
+			fmt.Println(strings.Join(row[:6], ","))
+		}
+		if similarityOutput == "" {
+			return nil
+		}
+		if err := util.WriteExcelSheets(similarityOutput, []util.Sheet{{
+			Name:    "相似度",
+			Headers: []string{"实验", "姓名A", "学号A", "姓名B", "学号B", "相似度", "共同段落", "文件A", "文件B"},
+			Rows:    rows,
+		}}); err != nil {
+			return err
+		}
+		fmt.Println("The similarity report is saved in", similarityOutput)
+		return nil
+	},
+}
+
+func init() {
+	labCmd.AddCommand(labSimilarityCmd)
+
+	labSimilarityCmd.Flags().Float64Var(&similarityThreshold, "threshold", 0.5, "list the pairs with a higher similarity")
+	labSimilarityCmd.Flags().StringVarP(&similarityOutput, "output", "o", "", "save the pairs into the given xlsx file")
+	labSimilarityCmd.Flags().IntVar(&shingleSize, "shingle", 5, "the number of characters of a shingle")
+}
+
+// readLabDocuments reads the .docx reports in the lab folder, the student of a report is
+// resolved by the namelist, or by its filename if not found in the namelist.
+func readLabDocuments(labDir string, students []CourseStudent, minHash *util.MinHash) []*labDocument {
+	var docs []*labDocument
+	err := filepath.Walk(labDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.EqualFold(filepath.Ext(path), ".docx") {
+			return err
+		}
+		text, err := util.ReadDocxText(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Ignore the report:", err)
+			return nil
+		}
+		doc := &labDocument{FileName: filepath.Base(path), shingles: util.NewShingles(text, shingleSize)}
+		stem := strings.TrimSuffix(doc.FileName, filepath.Ext(doc.FileName))
+		if idx := resolveStudent(students, stem); idx != -1 {
+			doc.Name, doc.Sno = students[idx].Name, students[idx].Sno
+		} else if name, sno, _, err := ExtractLabInfoFromFileName(stem); err == nil {
+			doc.Name, doc.Sno = name, sno
+		}
+		doc.signature = minHash.Signature(doc.shingles)
+		docs = append(docs, doc)
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+	}
+	return docs
+}
+
+// findSimilarPairs compares the reports pairwise, and returns the pairs with a similarity
+// not lower than the threshold, from the most similar one.
+func findSimilarPairs(labName string, docs []*labDocument, threshold float64) []similarPair {
+	var pairs []similarPair
+	for i := 0; i < len(docs); i++ {
+		for j := i + 1; j < len(docs); j++ {
+			similarity := util.EstimateSimilarity(docs[i].signature, docs[j].signature)
+			if similarity < threshold {
+				continue
+			}
+			pairs = append(pairs, similarPair{
+				Lab:        labName,
+				A:          docs[i],
+				B:          docs[j],
+				Similarity: similarity,
+				// a passage shorter than 4 shingles is too common to be meaningful
+				Passages: docs[i].shingles.SharedPassages(docs[j].shingles, 4*shingleSize),
+			})
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].Similarity > pairs[j].Similarity
+	})
+	return pairs
+}
+
+func similarityRows(pairs []similarPair) [][]string {
+	var rows [][]string
+	for _, pair := range pairs {
+		passages := pair.Passages
+		if len(passages) > 5 {
+			passages = passages[:5]
+		}
+		rows = append(rows, []string{pair.Lab, pair.A.Name, pair.A.Sno, pair.B.Name, pair.B.Sno,
+			strconv.FormatFloat(pair.Similarity, 'f', 2, 64), strings.Join(passages, "\n"),
+			pair.A.FileName, pair.B.FileName})
+	}
+	return rows
+}
@@ -0,0 +1,216 @@
+/*
+Copyright © 2023 Lyu Lin <lvlin@whu.edu.cn>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jackeylu/mytools/util"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultFileNamePattern = "{name}-{sno}-{lab}"
+	// the placeholders of a filename template
+	nameField = "{name}"
+	snoField  = "{sno}"
+	labField  = "{lab}"
+)
+
+var defaultFileExtensions = []string{"doc", "docx", "zip", "rar"}
+
+// fileNameTemplate is the filename format of the lab submissions of a course, e.g.
+// "{name}-{sno}-{lab}" or "{sno}_{name}_{lab}". The fields are separated by '-', '_' or spaces,
+// and any of them is accepted when parsing a filename.
+type fileNameTemplate struct {
+	Pattern string
+	// Extensions are the allowed extensions in lower case without '.'
+	Extensions []string
+	// fields are the placeholders and literal words of the pattern in order
+	fields []string
+}
+
+// labFileTemplate returns the filename template of the coursename configured as
+//
+//	lab:
+//	  template:
+//	    python-2023-class-1:
+//	      pattern: "{sno}_{name}_{lab}"
+//	      extensions: [pdf]
+//
+// The default template is "{name}-{sno}-{lab}" with the extensions doc, docx, zip and rar.
+func labFileTemplate(coursename string) (*fileNameTemplate, error) {
+	pattern := viper.GetString("lab.template." + coursename + ".pattern")
+	if pattern == "" {
+		pattern = defaultFileNamePattern
+	}
+	extensions := viper.GetStringSlice("lab.template." + coursename + ".extensions")
+	if len(extensions) == 0 {
+		extensions = defaultFileExtensions
+	}
+	return parseFileNameTemplate(pattern, extensions)
+}
+
+func parseFileNameTemplate(pattern string, extensions []string) (*fileNameTemplate, error) {
+	t := &fileNameTemplate{Pattern: pattern, fields: splitFileNameFields(pattern)}
+	for _, ext := range extensions {
+		t.Extensions = append(t.Extensions, strings.ToLower(strings.TrimPrefix(ext, ".")))
+	}
+	seen := make(map[string]bool)
+	for _, field := range t.fields {
+		if strings.HasPrefix(field, "{") {
+			if field != nameField && field != snoField && field != labField {
+				return nil, fmt.Errorf("unknown field %s in filename template %q", field, pattern)
+			}
+			if seen[field] {
+				return nil, fmt.Errorf("duplicate field %s in filename template %q", field, pattern)
+			}
+			seen[field] = true
+		}
+	}
+	if !seen[nameField] && !seen[snoField] {
+		return nil, fmt.Errorf("filename template %q has neither %s nor %s", pattern, nameField, snoField)
+	}
+	return t, nil
+}
+
+func splitFileNameFields(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == '-' || r == '_' || r == ' '
+	})
+}
+
+// allows tells whether the extension of the file is allowed by the template
+func (t *fileNameTemplate) allows(fileName string) bool {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(fileName), "."))
+	for _, v := range t.Extensions {
+		if v == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// has tells whether the template has the placeholder
+func (t *fileNameTemplate) has(field string) bool {
+	for _, v := range t.fields {
+		if v == field {
+			return true
+		}
+	}
+	return false
+}
+
+// format returns the canonical filename of the student's submission with the extension, e.g. ".docx"
+func (t *fileNameTemplate) format(name, sno, labName, ext string) string {
+	return strings.NewReplacer(nameField, name, snoField, sno, labField, labName).Replace(t.Pattern) + ext
+}
+
+// parse extracts the name and sno of a file in the folder of labName, and returns a precise
+// error when the filename does not match the template, such as "missing sno" or
+// "lab field 'lab3' does not match folder 'lab4'".
+func (t *fileNameTemplate) parse(fileName, labName string) (name, sno string, err error) {
+	name, sno, lab, err := t.parseFields(fileName, labName)
+	if err != nil {
+		return
+	}
+	if t.has(labField) && !strings.EqualFold(lab, labName) {
+		err = fmt.Errorf("lab field '%s' does not match folder '%s'", lab, labName)
+	}
+	return
+}
+
+// parseFields extracts the name, sno and lab of a file without checking the lab against the folder,
+// the lab is empty if the template has no lab field or the filename is too short to have it.
+func (t *fileNameTemplate) parseFields(fileName, labName string) (name, sno, lab string, err error) {
+	if !t.allows(fileName) {
+		err = fmt.Errorf("extension '%s' is not allowed, expecting %s",
+			filepath.Ext(fileName), strings.Join(t.Extensions, ", "))
+		return
+	}
+	// the parts after the first '.' are ignored, e.g. "张三-2200001-lab1.final.docx"
+	stem := strings.SplitN(strings.TrimSpace(fileName), ".", 2)[0]
+	tokens := splitFileNameFields(stem)
+	if len(tokens) < len(t.fields) {
+		err = t.missingFields(tokens, labName)
+		return
+	}
+	// the lab field takes the extra tokens, as a lab name may contain '-', e.g. "Lab1-PHP开发环境搭建"
+	extra := len(tokens) - len(t.fields)
+	if extra > 0 && !t.has(labField) {
+		err = fmt.Errorf("unexpected field '%s', expecting %s", tokens[len(t.fields)], t.Pattern)
+		return
+	}
+	i := 0
+	for _, field := range t.fields {
+		switch field {
+		case nameField:
+			name = tokens[i]
+		case snoField:
+			sno = tokens[i]
+		case labField:
+			lab = strings.Join(tokens[i:i+1+extra], "-")
+			i += extra
+		default:
+			if !strings.EqualFold(field, tokens[i]) {
+				err = fmt.Errorf("field '%s' does not match '%s' of %s", tokens[i], field, t.Pattern)
+				return
+			}
+		}
+		i++
+	}
+	// the name and sno may be swapped
+	if t.has(nameField) && t.has(snoField) && !util.IsAllCharacterDigit(sno) && util.IsAllCharacterDigit(name) {
+		name, sno = sno, name
+	}
+	if t.has(snoField) && !util.IsAllCharacterDigit(sno) {
+		err = fmt.Errorf("sno '%s' is not a number", sno)
+		return
+	}
+	return
+}
+
+// missingFields tells which fields are missing from the tokens of a filename
+func (t *fileNameTemplate) missingFields(tokens []string, labName string) error {
+	hasSno, hasLab := false, false
+	for _, token := range tokens {
+		if util.IsAllCharacterDigit(token) {
+			hasSno = true
+		}
+		if strings.Contains(strings.ToLower(labName), strings.ToLower(token)) {
+			hasLab = true
+		}
+	}
+	var missing []string
+	if t.has(snoField) && !hasSno {
+		missing = append(missing, "sno")
+	}
+	if t.has(labField) && !hasLab {
+		missing = append(missing, "lab")
+	}
+	if len(missing) == 0 && t.has(nameField) {
+		missing = append(missing, "name")
+	}
+	return fmt.Errorf("missing %s, expecting %s", strings.Join(missing, " and "), t.Pattern)
+}
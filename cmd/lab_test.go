@@ -0,0 +1,744 @@
+package cmd
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackeylu/mytools/util"
+)
+
+var labStudents = []CourseStudent{
+	{Name: "张三", Sno: "2200001"},
+	{Name: "李四", Sno: "2200002"},
+	{Name: "赵六", Sno: "2200003"},
+}
+
+func defaultTemplate(t *testing.T) *fileNameTemplate {
+	t.Helper()
+	template, err := parseFileNameTemplate(defaultFileNamePattern, defaultFileExtensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return template
+}
+
+// createLabFiles creates empty files under the root directory, the keys of files are lab names
+func createLabFiles(t *testing.T, root string, files map[string][]string) {
+	t.Helper()
+	for lab, names := range files {
+		if err := os.MkdirAll(filepath.Join(root, lab), 0755); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range names {
+			if err := os.WriteFile(filepath.Join(root, lab, name), []byte(lab+name), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestTraverseFilesMissingLab(t *testing.T) {
+	root := t.TempDir()
+	createLabFiles(t, root, map[string][]string{"lab1": {"张三-2200001-lab1.docx"}})
+	report, err := traverseFiles(root, []string{"lab1", "lab3"}, labStudents, defaultTemplate(t))
+	if report != nil || err == nil || !strings.Contains(err.Error(), "lab lab3") {
+		t.Errorf("Expected an error of the missing lab3 before the walk, but got %v, %v", report, err)
+	}
+}
+
+func TestTraverseFiles(t *testing.T) {
+	root := t.TempDir()
+	createLabFiles(t, root, map[string][]string{
+		"lab1": {"张三-2200001-lab1.docx", "2200002-李四-lab1.doc", "bad.docx", "王五-2200009-lab1.docx"},
+		"lab2": {"张三_2200001_lab2.zip", "readme.txt"},
+	})
+
+	report, err := traverseFiles(root, []string{"lab1", "lab2"}, labStudents, defaultTemplate(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.found, []int{2, 1}) {
+		t.Errorf("Expected found [2 1], but got %v", report.found)
+	}
+	expected := [][]string{{"已提交", "已提交"}, {"已提交", ""}, {"", ""}}
+	if !reflect.DeepEqual(report.result, expected) {
+		t.Errorf("Expected result %v, but got %v", expected, report.result)
+	}
+	// readme.txt is skipped as its extension is not listed by the template
+	if !reflect.DeepEqual(report.illegalFileNames, [][]string{{"bad.docx"}, nil}) {
+		t.Errorf("unexpected illegal filenames %v", report.illegalFileNames)
+	}
+	expectedReasons := [][]string{{"missing sno and lab, expecting {name}-{sno}-{lab}"}, nil}
+	if !reflect.DeepEqual(report.illegalReasons, expectedReasons) {
+		t.Errorf("Expected illegal reasons %v, but got %v", expectedReasons, report.illegalReasons)
+	}
+	if !reflect.DeepEqual(report.notFounds, [][]string{{"王五-2200009-lab1.docx"}, nil}) {
+		t.Errorf("unexpected not founds %v", report.notFounds)
+	}
+
+	sheets := labReportSheets(report)
+	if len(sheets) != 7 {
+		t.Fatalf("Expected 7 sheets, but got %d", len(sheets))
+	}
+	if !reflect.DeepEqual(sheets[0].Rows[1], []string{"李四", "2200002", "已提交", ""}) {
+		t.Errorf("unexpected matrix row %v", sheets[0].Rows[1])
+	}
+	if !reflect.DeepEqual(sheets[1].Rows, [][]string{{"lab1", "2", "1"}, {"lab2", "1", "2"}}) {
+		t.Errorf("unexpected summary rows %v", sheets[1].Rows)
+	}
+
+	reportFile := filepath.Join(root, "report.xlsx")
+	if err := writeLabReport(reportFile, report); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+}
+
+// createZip writes a zip file with the given entries, the entries ending with '/' are folders
+func createZip(t *testing.T, zipFile string, entries []string, flags uint16) {
+	t.Helper()
+	f, err := os.Create(zipFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for _, entry := range entries {
+		writer, err := w.CreateHeader(&zip.FileHeader{Name: entry, Flags: flags})
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer.Write([]byte(entry))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInspectZip(t *testing.T) {
+	root := t.TempDir()
+	manifest := []string{"*.docx", "src/"}
+	testCases := []struct {
+		desc     string
+		entries  []string
+		flags    uint16
+		expected []string
+	}{
+		{
+			desc:    "完整提交",
+			entries: []string{"张三/", "张三/实验报告.DOCX", "张三/src/main.c"},
+		},
+		{
+			desc:     "缺少源代码",
+			entries:  []string{"实验报告.docx"},
+			expected: []string{"missing src/"},
+		},
+		{
+			desc:     "只有空文件夹",
+			entries:  []string{"张三/", "张三/src/"},
+			expected: []string{"empty archive"},
+		},
+		{
+			desc:     "加密的压缩包",
+			entries:  []string{"实验报告.docx", "src/main.c"},
+			flags:    0x1,
+			expected: []string{"password-protected archive"},
+		},
+	}
+	for i, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			zipFile := filepath.Join(root, fmt.Sprintf("%d.zip", i))
+			createZip(t, zipFile, tC.entries, tC.flags)
+			if _, got := inspectZip(zipFile, manifest); !reflect.DeepEqual(got, tC.expected) {
+				t.Errorf("Expected findings %v, but got %v", tC.expected, got)
+			}
+		})
+	}
+
+	broken := filepath.Join(root, "broken.zip")
+	os.WriteFile(broken, []byte("not a zip"), 0644)
+	if _, got := inspectZip(broken, manifest); len(got) != 1 || !strings.HasPrefix(got[0], "broken archive") {
+		t.Errorf("Expected broken archive, but got %v", got)
+	}
+
+	listed := filepath.Join(root, "listed.zip")
+	createZip(t, listed, []string{"张三/", "张三/实验报告.docx", "张三/src/a.c", "张三/src/b.c", "张三/src/c.c",
+		"张三/src/d.c", "张三/src/e.c"}, 0)
+	files, _ := inspectZip(listed, manifest)
+	expected := "6 files: 张三/实验报告.docx, 张三/src/a.c, 张三/src/b.c, 张三/src/c.c, 张三/src/d.c, ..."
+	if got := zipSummary(files); got != expected {
+		t.Errorf("Expected %s, but got %s", expected, got)
+	}
+
+	os.Mkdir(filepath.Join(root, "lab1"), 0755)
+	createZip(t, filepath.Join(root, "lab1", "张三-2200001-lab1.zip"), []string{"实验报告.docx"}, 0)
+	report, err := traverseFiles(root, []string{"lab1"}, labStudents, defaultTemplate(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows := findingRows(report); !reflect.DeepEqual(rows, [][]string{
+		{"张三", "2200001", "lab1", "已提交", "", "1 file: 实验报告.docx"}}) {
+		t.Errorf("Unexpected finding rows %v", rows)
+	}
+}
+
+func TestPlanNormalize(t *testing.T) {
+	root := t.TempDir()
+	createLabFiles(t, root, map[string][]string{
+		"lab1": {"2200002-李四-lab1.doc", "张三-2200001-lab1.docx", "bad.docx"},
+		"lab2": {"实验二-2200003.docx", "张三-lab2.doc", "张三_2200001_lab2.doc", "李四-2200002-lab1.docx"},
+	})
+
+	plans := planNormalize(root, []string{"lab1", "lab2"}, labStudents, defaultTemplate(t))
+	got := make(map[string]string)
+	for _, plan := range plans {
+		got[filepath.Base(plan.From)] = filepath.Base(plan.To) + " " + plan.Status
+	}
+	expected := map[string]string{
+		"2200002-李四-lab1.doc":  "李四-2200002-lab1.doc ",
+		"张三-2200001-lab1.docx": "张三-2200001-lab1.docx already normalized",
+		"bad.docx":             ". student not resolved",
+		"实验二-2200003.docx":     "赵六-2200003-lab2.docx ",
+		"张三-lab2.doc":          "张三-2200001-lab2.doc ",
+		"张三_2200001_lab2.doc":  "张三-2200001-lab2.doc target exists",
+		"李四-2200002-lab1.docx": ". lab mismatch",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected plans %v, but got %v", expected, got)
+	}
+
+	if err := applyRenames(root, plans); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "lab2", "赵六-2200003-lab2.docx")); err != nil {
+		t.Errorf("Expected the file renamed, but got %v", err)
+	}
+	if err := undoRenames(root); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	for lab, name := range map[string]string{"lab1": "2200002-李四-lab1.doc", "lab2": "实验二-2200003.docx"} {
+		if _, err := os.Stat(filepath.Join(root, lab, name)); err != nil {
+			t.Errorf("Expected the original name restored, but got %v", err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, normalizeJournalFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected the journal removed after undo, but got %v", err)
+	}
+}
+
+func TestFindSimilarPairs(t *testing.T) {
+	minHash := util.NewMinHash(minHashSize)
+	newDoc := func(name, text string) *labDocument {
+		doc := &labDocument{Name: name, shingles: util.NewShingles(text, 5)}
+		doc.signature = minHash.Signature(doc.shingles)
+		return doc
+	}
+	report := "本实验通过编写一个简单的留言板程序，掌握表单的提交、数据的校验以及数据库的增删改查操作。"
+	docs := []*labDocument{
+		newDoc("张三", report+"实验总结：收获很大。"),
+		newDoc("李四", "Python的列表推导式和生成器表达式可以让代码更加简洁，也更加高效。"),
+		newDoc("赵六", report),
+	}
+
+	pairs := findSimilarPairs("lab1", docs, 0.5)
+	if len(pairs) != 1 {
+		t.Fatalf("Expected 1 pair, but got %d", len(pairs))
+	}
+	if pairs[0].A.Name != "张三" || pairs[0].B.Name != "赵六" {
+		t.Errorf("Expected 张三 and 赵六, but got %s and %s", pairs[0].A.Name, pairs[0].B.Name)
+	}
+	if len(pairs[0].Passages) != 1 || pairs[0].Passages[0] != report {
+		t.Errorf("Expected the shared passage %q, but got %q", report, pairs[0].Passages)
+	}
+}
+
+func TestIdenticalContent(t *testing.T) {
+	root := t.TempDir()
+	createLabFiles(t, root, map[string][]string{
+		"lab1": {"张三-2200001-lab1.docx", "李四-2200002-lab1.docx"},
+		"lab2": {"张三-2200001-lab2.docx"},
+	})
+	// 李四 copies the report of 张三, and 张三 submits the same report to lab2
+	copyFile := func(from, to string) {
+		data, _ := os.ReadFile(filepath.Join(root, from))
+		os.WriteFile(filepath.Join(root, to), data, 0644)
+	}
+	copyFile("lab1/张三-2200001-lab1.docx", "lab1/李四-2200002-lab1.docx")
+	copyFile("lab1/张三-2200001-lab1.docx", "lab2/张三-2200001-lab2.docx")
+
+	report, err := traverseFiles(root, []string{"lab1", "lab2"}, labStudents, defaultTemplate(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := identicalContentGroups(report.hashes)
+	if len(groups) != 1 || len(groups[0]) != 3 {
+		t.Fatalf("Expected 1 group of 3 files, but got %v", groups)
+	}
+
+	manifest := filepath.Join(root, "lab.sha256")
+	if err := writeManifest(manifest, report.hashes); err != nil {
+		t.Fatal(err)
+	}
+	if failed, err := verifyManifest(root, manifest); err != nil || failed != 0 {
+		t.Errorf("Expected all files verified, but got %d failed, err %v", failed, err)
+	}
+	os.WriteFile(filepath.Join(root, "lab2", "张三-2200001-lab2.docx"), []byte("changed"), 0644)
+	if failed, err := verifyManifest(root, manifest); err != nil || failed != 1 {
+		t.Errorf("Expected 1 file failed, but got %d failed, err %v", failed, err)
+	}
+}
+
+func TestFileNameTemplate(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		pattern    string
+		extensions []string
+		fileName   string
+		labName    string
+		name       string
+		sno        string
+		err        string
+	}{
+		{
+			desc:     "默认模板",
+			pattern:  defaultFileNamePattern,
+			fileName: "张三-2200001-Lab1-PHP开发环境搭建.docx",
+			labName:  "Lab1-PHP开发环境搭建",
+			name:     "张三",
+			sno:      "2200001",
+		},
+		{
+			desc:     "默认模板，姓名学号颠倒且使用空格和下划线",
+			pattern:  defaultFileNamePattern,
+			fileName: "2200001 张三_lab1.doc",
+			labName:  "lab1",
+			name:     "张三",
+			sno:      "2200001",
+		},
+		{
+			desc:       "学号在前的模板",
+			pattern:    "{sno}_{name}_{lab}",
+			extensions: []string{"pdf"},
+			fileName:   "2200001_张三_lab3.pdf",
+			labName:    "lab3",
+			name:       "张三",
+			sno:        "2200001",
+		},
+		{
+			desc:       "没有姓名的模板",
+			pattern:    "{lab}-{sno}",
+			extensions: []string{".ipynb"},
+			fileName:   "lab4-2200001.ipynb",
+			labName:    "lab4",
+			sno:        "2200001",
+		},
+		{
+			desc:       "实验名与文件夹不一致",
+			pattern:    "{lab}-{sno}",
+			extensions: []string{"ipynb"},
+			fileName:   "lab3-2200001.ipynb",
+			labName:    "lab4",
+			err:        "lab field 'lab3' does not match folder 'lab4'",
+		},
+		{
+			desc:       "缺少学号",
+			pattern:    "{sno}_{name}_{lab}",
+			extensions: []string{"pdf"},
+			fileName:   "张三_lab3.pdf",
+			labName:    "lab3",
+			err:        "missing sno, expecting {sno}_{name}_{lab}",
+		},
+		{
+			desc:       "缺少姓名",
+			pattern:    "{sno}_{name}_{lab}",
+			extensions: []string{"pdf"},
+			fileName:   "2200001_lab3.pdf",
+			labName:    "lab3",
+			err:        "missing name, expecting {sno}_{name}_{lab}",
+		},
+		{
+			desc:       "学号不是数字",
+			pattern:    "{sno}_{name}_{lab}",
+			extensions: []string{"pdf"},
+			fileName:   "张三_李四_lab3.pdf",
+			labName:    "lab3",
+			err:        "sno '张三' is not a number",
+		},
+		{
+			desc:       "扩展名不允许",
+			pattern:    "{sno}_{name}_{lab}",
+			extensions: []string{"pdf"},
+			fileName:   "2200001_张三_lab3.docx",
+			labName:    "lab3",
+			err:        "extension '.docx' is not allowed, expecting pdf",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			extensions := tC.extensions
+			if extensions == nil {
+				extensions = defaultFileExtensions
+			}
+			template, err := parseFileNameTemplate(tC.pattern, extensions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			name, sno, err := template.parse(tC.fileName, tC.labName)
+			if tC.err != "" {
+				if err == nil || err.Error() != tC.err {
+					t.Errorf("Expected error %q, but got %v", tC.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+			if name != tC.name || sno != tC.sno {
+				t.Errorf("Expected %s %s, but got %s %s", tC.name, tC.sno, name, sno)
+			}
+		})
+	}
+
+	if _, err := parseFileNameTemplate("{name}-{no}-{lab}", defaultFileExtensions); err == nil {
+		t.Error("Expected error on unknown field, but got nil")
+	}
+	template, _ := parseFileNameTemplate("{sno}_{name}_{lab}", []string{"pdf"})
+	if got := template.format("张三", "2200001", "lab3", ".pdf"); got != "2200001_张三_lab3.pdf" {
+		t.Errorf("Expected 2200001_张三_lab3.pdf, but got %s", got)
+	}
+}
+
+func TestBuildReminders(t *testing.T) {
+	root := t.TempDir()
+	createLabFiles(t, root, map[string][]string{
+		"lab1": {"张三-2200001-lab1.docx", "李四-2200002-lab1.docx"},
+		"lab2": {"张三-2200001-lab2.docx"},
+	})
+	students := append([]CourseStudent(nil), labStudents...)
+	students[2].Email = "zhaoliu@example.com"
+	report, err := traverseFiles(root, []string{"lab1", "lab2"}, students, defaultTemplate(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remindDomain = "stu.example.edu.cn"
+	defer func() { remindDomain = "" }()
+	reminders, err := buildReminders(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reminders) != 2 {
+		t.Fatalf("Expected 2 reminders, but got %d", len(reminders))
+	}
+	if reminders[0].To != "2200002@stu.example.edu.cn" || !reflect.DeepEqual(reminders[0].data.Missing, []string{"lab2"}) {
+		t.Errorf("Unexpected reminder %+v", reminders[0])
+	}
+	if reminders[1].To != "zhaoliu@example.com" || !reflect.DeepEqual(reminders[1].data.Missing, []string{"lab1", "lab2"}) {
+		t.Errorf("Unexpected reminder %+v", reminders[1])
+	}
+	if !strings.Contains(reminders[1].Body, "赵六同学") || !strings.Contains(reminders[1].Body, "- lab2") {
+		t.Errorf("Unexpected body %q", reminders[1].Body)
+	}
+
+	msg := string(reminders[1].message("teacher@example.com", time.Unix(0, 0)))
+	for _, want := range []string{"To: zhaoliu@example.com\r\n", "Subject: =?UTF-8?b?", "Content-Transfer-Encoding: base64\r\n\r\n"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Expected message to contain %q, but got %q", want, msg)
+		}
+	}
+}
+
+func TestCheckDocxContent(t *testing.T) {
+	template := []string{"实验报告", "姓名：", "学号：", "一、实验目的", "二、实验内容", "三、实验总结"}
+	minHash := util.NewMinHash(minHashSize)
+	rules := &docxRules{
+		MinWords:              40,
+		Headings:              []string{"实验目的", "实验内容", "实验总结"},
+		Identity:              true,
+		MaxTemplateSimilarity: defaultTemplateSimilarity,
+		minHash:               minHash,
+		templateSignature:     minHash.Signature(util.NewShingles(strings.Join(template, "\n"), 5)),
+	}
+	student := labStudents[0]
+
+	findings := checkDocxContent(template, student, rules)
+	expected := []string{"only 23 words, expecting at least 40", "name 张三 not in document", "sno 2200001 not in document"}
+	if len(findings) != 4 || !reflect.DeepEqual(findings[:3], expected) || !strings.HasPrefix(findings[3], "same as the template") {
+		t.Errorf("Expected %v and the template finding, but got %v", expected, findings)
+	}
+
+	report := []string{"实验报告", "姓名：张 三", "学号：2200001", "一、实验目的",
+		"掌握Go语言的基本语法，能够使用cobra编写命令行程序。",
+		"二、实验内容", "编写一个命令行工具，读取Excel文件中的学生名单，并统计实验报告的提交情况。"}
+	findings = checkDocxContent(report, student, rules)
+	if !reflect.DeepEqual(findings, []string{"missing section 实验总结"}) {
+		t.Errorf("Expected the missing section, but got %v", findings)
+	}
+}
+
+func TestPlanArchive(t *testing.T) {
+	root := t.TempDir()
+	createLabFiles(t, root, map[string][]string{
+		"lab1": {"张三-2200001-lab1.docx", "李四-2200002-lab1.DOCX", "李四-2200002-lab1.zip"},
+		"lab2": {"张三-2200001-lab2.docx"},
+	})
+	report, err := traverseFiles(root, []string{"lab1", "lab2"}, labStudents, defaultTemplate(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := planArchive(report, "class", defaultArchivePattern); err == nil {
+		t.Error("Expected an error of the illegal --by")
+	}
+	entries, err := planArchive(report, archiveByLab, "{lab}/{sno}_{name}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Archive+":"+entry.Name)
+	}
+	expected := []string{"lab1.zip:lab1/2200001_张三.docx", "lab1.zip:lab1/2200002_李四.docx",
+		"lab1.zip:lab1/2200002_李四.zip", "lab2.zip:lab2/2200001_张三.docx"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, but got %v", expected, got)
+	}
+
+	out := t.TempDir()
+	if err := writeArchives(root, out, entries); err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.OpenReader(filepath.Join(out, "lab1.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if len(r.File) != 3 || r.File[0].Name != "lab1/2200001_张三.docx" {
+		t.Errorf("Unexpected archive entries %v", r.File)
+	}
+
+	sheets := archiveIndexSheets(report, entries)
+	if len(sheets[0].Rows) != 4 || !reflect.DeepEqual(sheets[1].Rows, [][]string{
+		{"2200002", "李四", "", "lab2"}, {"2200003", "赵六", "", "lab1,lab2"}}) {
+		t.Errorf("Unexpected index %v", sheets)
+	}
+}
+
+func TestPlanArchiveClasses(t *testing.T) {
+	root := t.TempDir()
+	createLabFiles(t, root, map[string][]string{"lab1": {"张三-2200001-lab1.docx", "李四-2200002-lab1.docx"}})
+	students := []CourseStudent{{Name: "张三", Sno: "2200001", Class: "class1"}, {Name: "李四", Sno: "2200002", Class: "class2"}}
+	report, err := traverseFiles(root, []string{"lab1"}, students, defaultTemplate(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := planArchive(report, archiveByLab, defaultArchivePattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Archive+":"+entry.Name)
+	}
+	expected := []string{"class1-lab1.zip:2200001-张三-lab1.docx", "class2-lab1.zip:2200002-李四-lab1.docx"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, but got %v", expected, got)
+	}
+}
+
+func TestMatchStudent(t *testing.T) {
+	students := append([]CourseStudent{{Name: "张三", Sno: "2200004"}}, labStudents...)
+	cases := []struct {
+		name, sno string
+		idx       int
+		tier      matchTier
+	}{
+		{"李四", "2200002", 2, matchExact},
+		{"lisi", "2200002", 2, matchSno},
+		{"李西", "2200002", 2, matchSno},
+		{"赵六", "2200009", 3, matchName},
+		// 张三 is not unique in the namelist
+		{"张三", "2200009", -1, matchNone},
+		{"王五", "2200009", -1, matchNone},
+	}
+	for _, c := range cases {
+		idx, tier := matchStudent(students, defaultTemplate(t), c.name, c.sno)
+		if idx != c.idx || tier != c.tier {
+			t.Errorf("%s %s: expected %d %v, but got %d %v", c.name, c.sno, c.idx, c.tier, idx, tier)
+		}
+	}
+
+	root := t.TempDir()
+	createLabFiles(t, root, map[string][]string{
+		"lab1": {"lisi-2200002-lab1.docx", "赵六-2200030-lab1.docx"},
+	})
+	report, err := traverseFiles(root, []string{"lab1"}, labStudents, defaultTemplate(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.found, []int{2}) {
+		t.Errorf("Expected found [2], but got %v", report.found)
+	}
+	expected := [][]string{
+		{"lab1", "lisi-2200002-lab1.docx", "sno only", "李四", "2200002", "lisi", "2200002"},
+		{"lab1", "赵六-2200030-lab1.docx", "name only", "赵六", "2200003", "赵六", "2200030"},
+	}
+	if rows := mismatchRows(report); !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Expected %v, but got %v", expected, rows)
+	}
+}
+
+func TestMatchStudentSnoTemplate(t *testing.T) {
+	template, err := parseFileNameTemplate("{lab}-{sno}", []string{"ipynb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	createLabFiles(t, root, map[string][]string{"lab1": {"lab1-2200002.ipynb", "lab1-2200009.ipynb"}})
+	report, err := traverseFiles(root, []string{"lab1"}, labStudents, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.found, []int{1}) || len(report.mismatches) != 0 {
+		t.Errorf("Expected 李四 found exactly, but got %v with mismatches %v", report.found, report.mismatches)
+	}
+	if !reflect.DeepEqual(report.notFounds, [][]string{{"lab1-2200009.ipynb"}}) {
+		t.Errorf("Expected the unknown sno not found, but got %v", report.notFounds)
+	}
+}
+
+func TestLabReportClasses(t *testing.T) {
+	root := t.TempDir()
+	createLabFiles(t, root, map[string][]string{
+		"lab1": {"张三-2200001-lab1.docx", "李四-2200002-lab1.docx", "赵六-2200003-lab1.docx"},
+		"lab2": {"赵六-2200003-lab2.docx"},
+	})
+	students := append([]CourseStudent(nil), labStudents...)
+	students[0].Class, students[1].Class, students[2].Class = "class1", "class1", "class2"
+	report, err := traverseFiles(root, []string{"lab1", "lab2"}, students, defaultTemplate(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(report.classes(), []string{"class1", "class2"}) {
+		t.Errorf("Expected class1 and class2, but got %v", report.classes())
+	}
+	sheets := labReportSheets(report)
+	if len(sheets) != 8 {
+		t.Fatalf("Expected 8 sheets, but got %d", len(sheets))
+	}
+	if !reflect.DeepEqual(sheets[0].Rows[2], []string{"赵六", "2200003", "class2", "已提交", "已提交"}) {
+		t.Errorf("unexpected matrix row %v", sheets[0].Rows[2])
+	}
+	expected := [][]string{{"class1", "lab1", "2", "0"}, {"class1", "lab2", "0", "2"},
+		{"class2", "lab1", "1", "0"}, {"class2", "lab2", "1", "0"}}
+	if !reflect.DeepEqual(sheets[7].Rows, expected) {
+		t.Errorf("Expected %v, but got %v", expected, sheets[7].Rows)
+	}
+	if err := writeLabReport(filepath.Join(t.TempDir(), "report.xlsx"), report); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadNameList(t *testing.T) {
+	if _, err := ReadNameList(filepath.Join(t.TempDir(), "missing.xlsx")); !errors.Is(err, util.ErrFileNotFound) {
+		t.Errorf("Expected ErrFileNotFound, but got %v", err)
+	}
+
+	excelFile := filepath.Join(t.TempDir(), "namelist.xlsx")
+	if err := util.WriteExcelSheets(excelFile, []util.Sheet{{Name: "Sheet1", Headers: []string{"姓名", "学号"},
+		Rows: [][]string{{"张三", "2200001"}, {"李四"}, {"赵六", "2200003", "zhaoliu@example.com"}, {"王五", "2200009", "", "x"}}}}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ReadNameList(excelFile)
+	var rows []int
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var malformed *ErrRosterMalformed
+		if errors.As(e, &malformed) {
+			rows = append(rows, malformed.Row)
+		}
+	}
+	if !reflect.DeepEqual(rows, []int{3, 5}) {
+		t.Errorf("Expected the malformed rows 3 and 5, but got %v: %v", rows, err)
+	}
+}
+
+func TestWatchSubmissions(t *testing.T) {
+	root := t.TempDir()
+	createLabFiles(t, root, map[string][]string{"lab1": {"张三-2200001-lab1.docx"}})
+	report, err := traverseFiles(root, []string{"lab1"}, labStudents, defaultTemplate(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	submitted, withdrawn := make(chan int, 1), make(chan int, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- watchSubmissions(ctx, root, defaultTemplate(t), report, 50*time.Millisecond,
+			func(studentIndex, labIndex int) {
+				changes := submitted
+				if report.result[studentIndex][labIndex] == "" {
+					changes = withdrawn
+				}
+				// a file written again is submitted again, the test has stopped waiting for it
+				select {
+				case changes <- studentIndex:
+				default:
+				}
+			})
+	}()
+	// the file is written in pieces, like being copied, and written again until the watcher is ready
+	file := filepath.Join(root, "lab1", "李四-2200002-lab1.docx")
+	deadline := time.After(5 * time.Second)
+	for waiting := true; waiting; {
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			f.WriteString("part")
+		}
+		f.Close()
+
+		select {
+		case idx := <-submitted:
+			if idx != 1 {
+				t.Errorf("Expected 李四 submitted, but got %d", idx)
+			}
+			waiting = false
+		case <-time.After(200 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("Timeout waiting for the submission")
+		}
+	}
+
+	// the file renamed to an illegal name withdraws the submission of 张三
+	if err := os.Rename(filepath.Join(root, "lab1", "张三-2200001-lab1.docx"),
+		filepath.Join(root, "lab1", "张三.docx")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case idx := <-withdrawn:
+		if idx != 0 {
+			t.Errorf("Expected 张三 withdrawn, but got %d", idx)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for the withdrawal")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if liveCount(report) != "lab1 1/3" || len(report.hashes) != 1 || report.result[0][0] != "" {
+		t.Errorf("Expected lab1 1/3 with 1 hash, but got %s with %d hashes", liveCount(report), len(report.hashes))
+	}
+}
@@ -0,0 +1,242 @@
+/*
+Copyright © 2023 Lyu Lin <lvlin@whu.edu.cn>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+var (
+	watchLabs     bool
+	watchDebounce time.Duration
+)
+
+// debouncer sends a path to ready after no event of the path for the delay,
+// so that a file being copied is checked only once it is complete.
+type debouncer struct {
+	mu     sync.Mutex
+	delay  time.Duration
+	timers map[string]*time.Timer
+	ready  chan string
+	// done is closed by stop, so the fired timers do not block on ready after the watch ends
+	done chan struct{}
+}
+
+func newDebouncer(delay time.Duration) *debouncer {
+	return &debouncer{delay: delay, timers: make(map[string]*time.Timer), ready: make(chan string, 16),
+		done: make(chan struct{})}
+}
+
+// touch restarts the delay of the path
+func (d *debouncer) touch(path string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if timer, ok := d.timers[path]; ok {
+		timer.Reset(d.delay)
+		return
+	}
+	d.timers[path] = time.AfterFunc(d.delay, func() {
+		d.mu.Lock()
+		delete(d.timers, path)
+		d.mu.Unlock()
+		select {
+		case d.ready <- path:
+		case <-d.done:
+		}
+	})
+}
+
+// stop cancels the pending paths and releases the fired ones, it is called once
+func (d *debouncer) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	close(d.done)
+	for path, timer := range d.timers {
+		timer.Stop()
+		delete(d.timers, path)
+	}
+}
+
+// forget removes the results of the file in the lab, so that the file can be checked again or is gone.
+// It returns the student whose submission is cleared by the removal, -1 if none.
+func (r *labReport) forget(labIndex int, relPath string) int {
+	labName, fileName := r.labsName[labIndex], filepath.Base(relPath)
+	r.illegalFileNames[labIndex], r.illegalReasons[labIndex] =
+		removeFileName(r.illegalFileNames[labIndex], r.illegalReasons[labIndex], fileName)
+	r.notFounds[labIndex], _ = removeFileName(r.notFounds[labIndex], nil, fileName)
+	mismatches := r.mismatches[:0]
+	for _, m := range r.mismatches {
+		if m.Lab != labName || m.FileName != fileName {
+			mismatches = append(mismatches, m)
+		}
+	}
+	r.mismatches = mismatches
+
+	studentIndex := -1
+	hashes := r.hashes[:0]
+	for _, h := range r.hashes {
+		if h.Path == relPath {
+			studentIndex = h.StudentIndex
+		} else {
+			hashes = append(hashes, h)
+		}
+	}
+	r.hashes = hashes
+	if studentIndex == -1 {
+		return -1
+	}
+	// a duplicate file of the student still holds the submission
+	for _, h := range r.hashes {
+		if h.Lab == labName && h.StudentIndex == studentIndex {
+			return -1
+		}
+	}
+	if r.result[studentIndex][labIndex] != "" {
+		r.result[studentIndex][labIndex] = ""
+		r.found[labIndex]--
+	}
+	r.findings[studentIndex][labIndex] = nil
+	r.contents[studentIndex][labIndex] = ""
+	return studentIndex
+}
+
+// removeFileName removes the file name and its reason at the same position, the reasons may be nil
+func removeFileName(fileNames, reasons []string, fileName string) ([]string, []string) {
+	for k := len(fileNames) - 1; k >= 0; k-- {
+		if fileNames[k] != fileName {
+			continue
+		}
+		fileNames = append(fileNames[:k], fileNames[k+1:]...)
+		if reasons != nil {
+			reasons = append(reasons[:k], reasons[k+1:]...)
+		}
+	}
+	return fileNames, reasons
+}
+
+// watchSubmissions watches the lab folders and records the changed files in the report until the context is done.
+// A written file is checked again and replaces its earlier result, a removed or renamed file clears its submission.
+// changed is called for every submission made or cleared, report.result tells which.
+// The files in the sub-folders of a lab are not watched.
+func watchSubmissions(ctx context.Context,
+	folderPath string,
+	template *fileNameTemplate,
+	report *labReport,
+	delay time.Duration,
+	changed func(studentIndex, labIndex int)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	labIndexes := make(map[string]int)
+	rules := make([]*docxRules, len(report.labsName))
+	for j, labName := range report.labsName {
+		labDir := filepath.Join(folderPath, labName)
+		if err := watcher.Add(labDir); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", labDir, err)
+		}
+		labIndexes[labDir] = j
+		if rules[j], err = loadDocxRules(labName); err != nil {
+			return err
+		}
+	}
+	d := newDebouncer(delay)
+	defer d.stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if _, ok := labIndexes[filepath.Dir(event.Name)]; !ok || ignoredFile(filepath.Base(event.Name)) {
+				continue
+			}
+			// a removed or renamed path is found gone once ready
+			if event.Has(fsnotify.Create) || event.Has(fsnotify.Write) ||
+				event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+				d.touch(event.Name)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Fprintln(os.Stderr, "Watch error:", err)
+		case path := <-d.ready:
+			j := labIndexes[filepath.Dir(path)]
+			relPath, _ := filepath.Rel(folderPath, path)
+			cleared := report.forget(j, relPath)
+			idx := -1
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				idx = processOneFile(path, folderPath, template, j, report.labsName[j], rules[j], report)
+			}
+			if idx != -1 {
+				changed(idx, j)
+			} else if cleared != -1 {
+				changed(cleared, j)
+			}
+		}
+	}
+}
+
+// watchLabSubmissions prints the new submissions and the live count of every lab until interrupted
+func watchLabSubmissions(report *labReport) error {
+	template, err := labFileTemplate(labCourseName())
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Printf("Watching %s, press Ctrl+C to stop.\n", strings.Join(report.labsName, ","))
+	fmt.Println(liveCount(report))
+	return watchSubmissions(ctx, workingDir, template, report, watchDebounce, func(studentIndex, labIndex int) {
+		student, action := report.students[studentIndex], "submitted"
+		if report.result[studentIndex][labIndex] == "" {
+			action = "withdrew"
+		}
+		fmt.Printf("[%s] %s %s %s %s\n", time.Now().Format("15:04:05"),
+			student.Name, student.Sno, action, report.labsName[labIndex])
+		fmt.Println(liveCount(report))
+	})
+}
+
+// liveCount shows the submitted count of every lab, e.g. "lab1 12/40, lab2 3/40"
+func liveCount(report *labReport) string {
+	counts := make([]string, len(report.labsName))
+	for j, labName := range report.labsName {
+		counts[j] = fmt.Sprintf("%s %d/%d", labName, report.found[j], len(report.students))
+	}
+	return strings.Join(counts, ", ")
+}
@@ -0,0 +1,110 @@
+/*
+Copyright © 2023 Lyu Lin <lvlin@whu.edu.cn>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"archive/zip"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// labManifest returns the required entries of the zip submissions of a lab, configured as
+//
+//	lab:
+//	  manifest:
+//	    lab1: ["*.docx", "src/"]
+//
+// An entry ending with '/' requires a non-empty folder, others are patterns matching the file names.
+func labManifest(labName string) []string {
+	return viper.GetStringSlice("lab.manifest." + labName)
+}
+
+// zipSummaryFiles is the number of the files named in the summary of a zip submission
+const zipSummaryFiles = 5
+
+// inspectZip lists the files of a zip submission, and returns the findings of it,
+// such as an empty or password-protected archive, or the missing entries required by the manifest.
+func inspectZip(zipFile string, manifest []string) (files []string, findings []string) {
+	r, err := zip.OpenReader(zipFile)
+	if err != nil {
+		return nil, []string{fmt.Sprintf("broken archive: %v", err)}
+	}
+	defer r.Close()
+
+	encrypted := false
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		files = append(files, f.Name)
+		// bit 0 of the general purpose flag tells the entry is encrypted
+		if f.Flags&0x1 != 0 {
+			encrypted = true
+		}
+	}
+	if len(files) == 0 {
+		return nil, []string{"empty archive"}
+	}
+	if encrypted {
+		findings = append(findings, "password-protected archive")
+	}
+	for _, required := range manifest {
+		if !zipContains(files, required) {
+			findings = append(findings, "missing "+required)
+		}
+	}
+	return files, findings
+}
+
+// zipSummary tells the number of the files and the first names of them,
+// e.g. "7 files: 张三/实验报告.docx, 张三/src/main.c, ..."
+func zipSummary(files []string) string {
+	if len(files) == 0 {
+		return ""
+	}
+	summary := fmt.Sprintf("%d files: ", len(files))
+	if len(files) == 1 {
+		summary = "1 file: "
+	}
+	if len(files) <= zipSummaryFiles {
+		return summary + strings.Join(files, ", ")
+	}
+	return summary + strings.Join(files[:zipSummaryFiles], ", ") + ", ..."
+}
+
+// zipContains tells whether any file of the archive satisfies the required manifest entry
+func zipContains(files []string, required string) bool {
+	for _, name := range files {
+		if strings.HasSuffix(required, "/") {
+			// the folder may be nested in a top folder, e.g. "report/src/main.c"
+			if strings.HasPrefix(name, required) || strings.Contains(name, "/"+required) {
+				return true
+			}
+		} else if match, _ := path.Match(strings.ToLower(required), strings.ToLower(path.Base(name))); match {
+			return true
+		}
+	}
+	return false
+}
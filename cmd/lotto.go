@@ -18,6 +18,7 @@ package cmd
 import (
 	"fmt"
 	"math/rand"
+	"strconv"
 	"strings"
 	"time"
 
@@ -48,8 +49,28 @@ Args:
   [end]       结束数字，必须大于start
   [milliSecond] 休眠时间，单位毫秒，默认100ms
 
+抽取多名中奖者时，每人只能中奖一次。参与者可以来自名单文件，每行一个姓名或号码，
+可用逗号或制表符分隔出权重，如"张三,2"表示中奖机会是权重为1者的两倍。
+可以按顺序设置多轮抽奖，每次抽奖的时间、轮次和中奖者都会追加记录到结果文件中，
+默认为lotto.dir目录下的lotto-results.csv。
+
+示例:
+  mytools lotto -f entries.txt --round 三等奖=3 --round 二等奖=2 --round 一等奖=1
+  mytools lotto -s 1 -e 50 -n 3
+
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			rounds, err := parseLottoRounds(lottoRounds, lottoCount)
+			if err != nil {
+				return err
+			}
+			if entriesFile != "" {
+				entries, err := readLottoEntries(entriesFile)
+				if err != nil {
+					return err
+				}
+				return runLottoRounds(entries, rounds)
+			}
 			if start >= end || start < 0 {
 				return fmt.Errorf("start number must be less than end number and must be greater or equal then zero, but start = %d, end = %d",
 					start, end)
@@ -58,13 +79,20 @@ Args:
 				fmt.Println("negativte value for milliSecond, use default value 100ms.")
 				milliSecond = 100
 			}
-			work()
-			return nil
+			if len(rounds) > 1 || rounds[0].Count > 1 {
+				entries, err := numberEntries(start, end)
+				if err != nil {
+					return err
+				}
+				return runLottoRounds(entries, rounds)
+			}
+			num := work()
+			return logLottoResult(time.Now(), rounds[0].Name, strconv.FormatInt(num, 10))
 		},
 	}
 )
 
-func work() {
+func work() int64 {
 	fmt.Println("Press Enter to break.")
 	ch := make(chan int, 1)
 	// 如果用户有输入
@@ -84,7 +112,7 @@ func work() {
 		select {
 		case <-ch:
 			fmt.Printf("You have choosed %d\n", num)
-			return
+			return num
 		default:
 			fmt.Printf("\r%s\r", strings.Repeat(" ", getNumberLength(num)))
 		}
@@ -108,6 +136,11 @@ func init() {
 	rootCmd.AddCommand(lottoCmd)
 
 	lottoCmd.Flags().Int64VarP(&start, "start", "s", 1, "The starting number.")
-	lottoCmd.Flags().Int64VarP(&end, "end", "e", 100, "The ending number.")
-	lottoCmd.Flags().Int64VarP(&milliSecond, "ms", "m", 100, "The sleeping interval.")
+	lottoCmd.Flags().Int64VarP(&end, "end", "e", 100, "The ending number, excluded.")
+	lottoCmd.Flags().StringVarP(&entriesFile, "file", "f", "", "The entries file, one name or number per line with an optional weight.")
+	lottoCmd.Flags().IntVarP(&lottoCount, "number", "n", 1, "The number of the winners without repeats.")
+	lottoCmd.Flags().StringArrayVar(&lottoRounds, "round", nil, "The round and its winners drawn in order, e.g. 一等奖=1.")
+	lottoCmd.Flags().StringVar(&resultsFile, "results", "", "The file logging the draws, default is lotto-results.csv of lotto.dir.")
+	lottoCmd.Flags().BoolVar(&lottoNoSpin, "no-spin", false, "Show the winners without the spinning entries.")
+	lottoCmd.PersistentFlags().Int64VarP(&milliSecond, "ms", "m", 100, "The sleeping interval.")
 }
@@ -0,0 +1,229 @@
+/*
+Copyright © 2023 Lyu Lin <lvlin@whu.edu.cn>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// maxLottoNumbers is the maximum size of the range drawn without an entries file
+const maxLottoNumbers = 100000
+
+var (
+	entriesFile string
+	lottoCount  int
+	lottoRounds []string
+	resultsFile string
+	lottoNoSpin bool
+)
+
+// defaultLottoRound is the name of the round when no round is given
+const defaultLottoRound = "抽奖"
+
+// lottoEntry is an entry of the draw, an entry with the weight 2 is twice as likely to win as the weight 1
+type lottoEntry struct {
+	Name   string
+	Weight float64
+}
+
+// lottoRound is a prize level with the number of the winners
+type lottoRound struct {
+	Name  string
+	Count int
+}
+
+// readLottoEntries reads the entries of the file, one entry per line with an optional weight separated
+// by a comma or a tab, e.g. "张三,2". The empty lines and the lines starting with # are ignored.
+func readLottoEntries(file string) ([]lottoEntry, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []lottoEntry
+	seen := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == '，' || r == '\t' })
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, fmt.Errorf("%s line %d: expecting a name and an optional weight, but got '%s'", file, line, text)
+		}
+		entry := lottoEntry{Name: strings.TrimSpace(fields[0]), Weight: 1}
+		if len(fields) == 2 {
+			entry.Weight, err = strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+			if err != nil || entry.Weight <= 0 {
+				return nil, fmt.Errorf("%s line %d: illegal weight '%s'", file, line, fields[1])
+			}
+		}
+		if first, ok := seen[entry.Name]; ok {
+			return nil, fmt.Errorf("%s line %d: duplicate entry %s, first seen in line %d", file, line, entry.Name, first)
+		}
+		seen[entry.Name] = line
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no entry in %s", file)
+	}
+	return entries, nil
+}
+
+// numberEntries returns the numbers from start to end, excluding end, as the entries of the same weight
+func numberEntries(start, end int64) ([]lottoEntry, error) {
+	if end-start > maxLottoNumbers {
+		return nil, fmt.Errorf("more than %d numbers to draw several winners, use an entries file instead", maxLottoNumbers)
+	}
+	entries := make([]lottoEntry, 0, end-start)
+	for i := start; i < end; i++ {
+		entries = append(entries, lottoEntry{Name: strconv.FormatInt(i, 10), Weight: 1})
+	}
+	return entries, nil
+}
+
+// parseLottoRounds parses the rounds given as "一等奖=1" in the order of the draws,
+// a single round of count winners is returned if no round is given.
+func parseLottoRounds(specs []string, count int) ([]lottoRound, error) {
+	if len(specs) == 0 {
+		if count < 1 {
+			return nil, fmt.Errorf("the number of the winners should be positive, but got %d", count)
+		}
+		return []lottoRound{{Name: defaultLottoRound, Count: count}}, nil
+	}
+	var rounds []lottoRound
+	for _, spec := range specs {
+		name, value, ok := strings.Cut(spec, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || strings.TrimSpace(name) == "" || err != nil || n < 1 {
+			return nil, fmt.Errorf("illegal round %s, expecting <name>=<winners>, e.g. 一等奖=1", spec)
+		}
+		rounds = append(rounds, lottoRound{Name: strings.TrimSpace(name), Count: n})
+	}
+	return rounds, nil
+}
+
+// weightedEntry draws the index of an entry of the pool by the weights
+func weightedEntry(r *rand.Rand, pool []lottoEntry) int {
+	weights := make([]float64, len(pool))
+	for i, e := range pool {
+		weights[i] = e.Weight
+	}
+	return weightedIndex(r, weights)
+}
+
+// drawRounds draws the winners of the rounds in order, a winner does not win again. choose returns the
+// index of the winner of the round in the pool of the entries not won yet.
+func drawRounds(entries []lottoEntry, rounds []lottoRound, choose func(round int, pool []lottoEntry) int) ([][]lottoEntry, error) {
+	total := 0
+	for _, round := range rounds {
+		total += round.Count
+	}
+	if total > len(entries) {
+		return nil, fmt.Errorf("%d winners are drawn from %d entries", total, len(entries))
+	}
+	pool := append([]lottoEntry(nil), entries...)
+	winners := make([][]lottoEntry, len(rounds))
+	for i, round := range rounds {
+		for j := 0; j < round.Count; j++ {
+			k := choose(i, pool)
+			winners[i] = append(winners[i], pool[k])
+			pool = append(pool[:k], pool[k+1:]...)
+		}
+	}
+	return winners, nil
+}
+
+// runLottoRounds draws the winners of the rounds in turn, and logs every draw
+func runLottoRounds(entries []lottoEntry, rounds []lottoRound) error {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	var logErr error
+	current, drawn := -1, 0
+	_, err := drawRounds(entries, rounds, func(round int, pool []lottoEntry) int {
+		if round != current {
+			current, drawn = round, 0
+			fmt.Printf("%s (%d):\n", rounds[round].Name, rounds[round].Count)
+		}
+		k := weightedEntry(r, pool)
+		if !lottoNoSpin {
+			// every frame is a real draw, the entry shown when Enter is pressed wins
+			spin(func() string {
+				k = weightedEntry(r, pool)
+				return pool[k].Name
+			})
+		}
+		drawn++
+		fmt.Printf("%d. %s\n", drawn, pool[k].Name)
+		logErr = errors.Join(logErr, logLottoResult(time.Now(), rounds[round].Name, pool[k].Name))
+		return k
+	})
+	if err = errors.Join(err, logErr); err != nil {
+		return err
+	}
+	fmt.Println("The draws are logged in", lottoResultsFile())
+	return nil
+}
+
+func lottoResultsFile() string {
+	if resultsFile != "" {
+		return resultsFile
+	}
+	dir := viper.GetString("lotto.dir")
+	if dir == "" {
+		dir = "."
+	}
+	return filepath.Join(dir, "lotto-results.csv")
+}
+
+// logLottoResult appends the draw to the results file, the header is written into a new file
+func logLottoResult(at time.Time, round string, winner string) error {
+	file := lottoResultsFile()
+	_, err := os.Stat(file)
+	isNew := os.IsNotExist(err)
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	if isNew {
+		w.Write([]string{"time", "round", "winner"})
+	}
+	w.Write([]string{at.Format("2006-01-02 15:04:05"), round, winner})
+	w.Flush()
+	return w.Error()
+}
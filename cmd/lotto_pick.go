@@ -0,0 +1,323 @@
+/*
+Copyright © 2023 Lyu Lin <lvlin@whu.edu.cn>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jackeylu/mytools/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	pickClass    string
+	pickCount    int
+	pickWeighted bool
+	pickNoSpin   bool
+	pickOutput   string
+)
+
+// pickRecord is a student picked in class
+type pickRecord struct {
+	Time time.Time `json:"time"`
+	Sno  string    `json:"sno"`
+	Name string    `json:"name"`
+}
+
+// pickHistory keeps the picks of a class
+type pickHistory struct {
+	Class string       `json:"class"`
+	Picks []pickRecord `json:"picks"`
+}
+
+// lottoPickCmd represents the lotto pick command
+var lottoPickCmd = &cobra.Command{
+	Use:   "pick",
+	Short: "从班级名单中随机点名，可以一次抽取多名学生.",
+	Long: `Pick students at random from the namelist of a class, the names are shown in turn until Enter
+is pressed. The class is a namelist configured by 'lab.class.<class>', a namelist file, or a class
+of the roster registry.
+
+The picks are saved in '<class>.picks.json' of the directory 'lotto.dir', default is the current
+directory. With --weighted, a student picked less often is more likely to be picked, counting the
+picks since 'lotto.semester-start', e.g. 2023-09-01.
+
+Example:
+
+$ mytools lotto pick -c php-2023-class-1 -n 3 --weighted
+$ mytools lotto pick export -c php-2023-class-1 -o picks.xlsx`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		students, err := loadPickRoster(pickClass)
+		if err != nil {
+			return err
+		}
+		if pickCount < 1 || pickCount > len(students) {
+			return fmt.Errorf("the number of the picks should be between 1 and %d, but got %d", len(students), pickCount)
+		}
+		history, err := readPickHistory(pickClass)
+		if err != nil {
+			return err
+		}
+		var counts map[string]int
+		if pickWeighted {
+			counts = pickCounts(history, semesterStart())
+		}
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		remaining := append([]CourseStudent(nil), students...)
+		for i := 0; i < pickCount; i++ {
+			student := drawStudents(r, remaining, counts, 1)[0]
+			if !pickNoSpin {
+				// every frame is a real draw, the student shown when Enter is pressed is picked
+				spin(func() string {
+					student = drawStudents(r, remaining, counts, 1)[0]
+					return student.Name
+				})
+			}
+			remaining = removeStudent(remaining, student.Sno)
+			fmt.Printf("%d. %s %s\n", i+1, student.Name, student.Sno)
+			history.Picks = append(history.Picks, pickRecord{Time: time.Now(), Sno: student.Sno, Name: student.Name})
+		}
+		return writePickHistory(history)
+	},
+}
+
+var lottoPickExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "导出点名记录，用于课堂参与成绩.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		students, err := loadPickRoster(pickClass)
+		if err != nil {
+			return err
+		}
+		history, err := readPickHistory(pickClass)
+		if err != nil {
+			return err
+		}
+		if pickOutput == "" {
+			pickOutput = pickClass + "-picks.xlsx"
+		}
+		if err := util.WriteExcelSheets(pickOutput, pickSheets(students, history)); err != nil {
+			return err
+		}
+		fmt.Println("The picks are saved in", pickOutput)
+		return nil
+	},
+}
+
+func init() {
+	lottoCmd.AddCommand(lottoPickCmd)
+	lottoPickCmd.AddCommand(lottoPickExportCmd)
+
+	lottoPickCmd.PersistentFlags().StringVarP(&pickClass, "class", "c", "", "the class, a namelist file or a class of the registry")
+	lottoPickCmd.Flags().IntVarP(&pickCount, "number", "n", 1, "the number of the students to pick, without replacement")
+	lottoPickCmd.Flags().BoolVarP(&pickWeighted, "weighted", "w", false, "favor the students picked less often")
+	lottoPickCmd.Flags().BoolVar(&pickNoSpin, "no-spin", false, "show the picks without the spinning names")
+	lottoPickExportCmd.Flags().StringVarP(&pickOutput, "output", "o", "", "the xlsx file, default is <class>-picks.xlsx")
+}
+
+// loadPickRoster reads the namelist configured by lab.class.<class>, or the namelist file, or the class of the registry
+func loadPickRoster(class string) ([]CourseStudent, error) {
+	if class == "" {
+		return nil, fmt.Errorf("the class is not given by --class")
+	}
+	namelist := viper.GetString("lab.class." + class)
+	if namelist == "" {
+		namelist = class
+	}
+	students, err := readClassRoster(namelist)
+	if err != nil {
+		return nil, err
+	}
+	if len(students) == 0 {
+		return nil, fmt.Errorf("no student in %s", namelist)
+	}
+	return students, nil
+}
+
+func pickHistoryFile(class string) string {
+	dir := viper.GetString("lotto.dir")
+	if dir == "" {
+		dir = "."
+	}
+	// the class may be a namelist file
+	name := strings.TrimSuffix(filepath.Base(class), filepath.Ext(class))
+	return filepath.Join(dir, name+".picks.json")
+}
+
+func readPickHistory(class string) (*pickHistory, error) {
+	history := &pickHistory{Class: class}
+	data, err := os.ReadFile(pickHistoryFile(class))
+	if os.IsNotExist(err) {
+		return history, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, history); err != nil {
+		return nil, fmt.Errorf("broken pick history %s: %w", pickHistoryFile(class), err)
+	}
+	return history, nil
+}
+
+func writePickHistory(history *pickHistory) error {
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(pickHistoryFile(history.Class), data, 0644)
+}
+
+// semesterStart returns the date configured by lotto.semester-start, zero if not configured
+func semesterStart() time.Time {
+	start, err := time.ParseInLocation("2006-01-02", viper.GetString("lotto.semester-start"), time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return start
+}
+
+// pickCounts counts the picks of every student since the time
+func pickCounts(history *pickHistory, since time.Time) map[string]int {
+	counts := make(map[string]int)
+	for _, p := range history.Picks {
+		if !p.Time.Before(since) {
+			counts[p.Sno]++
+		}
+	}
+	return counts
+}
+
+// weightedIndex draws an index by the weights, the last index takes the rounding error of the total
+func weightedIndex(r *rand.Rand, weights []float64) int {
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	x := r.Float64() * total
+	i := 0
+	for ; i < len(weights)-1; i++ {
+		if x < weights[i] {
+			break
+		}
+		x -= weights[i]
+	}
+	return i
+}
+
+// drawStudents draws n students without replacement, a student picked k times has the weight 1/(k+1),
+// all the students have the same weight if counts is nil.
+func drawStudents(r *rand.Rand, students []CourseStudent, counts map[string]int, n int) []CourseStudent {
+	pool := append([]CourseStudent(nil), students...)
+	weights := make([]float64, len(pool))
+	for i, s := range pool {
+		weights[i] = 1 / float64(counts[s.Sno]+1)
+	}
+	var picked []CourseStudent
+	for len(picked) < n && len(pool) > 0 {
+		i := weightedIndex(r, weights)
+		picked = append(picked, pool[i])
+		pool = append(pool[:i], pool[i+1:]...)
+		weights = append(weights[:i], weights[i+1:]...)
+	}
+	return picked
+}
+
+// pickSheets lists the picks of every student, and all the picks in time order
+func pickSheets(students []CourseStudent, history *pickHistory) []util.Sheet {
+	counts := pickCounts(history, time.Time{})
+	last := make(map[string]time.Time)
+	for _, p := range history.Picks {
+		if p.Time.After(last[p.Sno]) {
+			last[p.Sno] = p.Time
+		}
+	}
+	summary := util.Sheet{Name: "点名统计", Headers: []string{"姓名", "学号", "次数", "最近一次"}, NumericColumns: []int{2}}
+	for _, s := range students {
+		lastTime := ""
+		if t, ok := last[s.Sno]; ok {
+			lastTime = t.Format("2006-01-02 15:04")
+		}
+		summary.Rows = append(summary.Rows, []string{s.Name, s.Sno, strconv.Itoa(counts[s.Sno]), lastTime})
+	}
+	picks := util.Sheet{Name: "点名记录", Headers: []string{"时间", "姓名", "学号"}}
+	sorted := append([]pickRecord(nil), history.Picks...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+	for _, p := range sorted {
+		picks.Rows = append(picks.Rows, []string{p.Time.Format("2006-01-02 15:04"), p.Name, p.Sno})
+	}
+	return []util.Sheet{summary, picks}
+}
+
+// removeStudent returns the students without the one of the sno
+func removeStudent(students []CourseStudent, sno string) []CourseStudent {
+	var rest []CourseStudent
+	for _, s := range students {
+		if s.Sno != sno {
+			rest = append(rest, s)
+		}
+	}
+	return rest
+}
+
+// spin shows the texts given by next in place until Enter is pressed, the last text returned by next
+// is the one on the screen when Enter is pressed, as work does.
+func spin(next func() string) {
+	fmt.Println("Press Enter to stop.")
+	ch := make(chan struct{})
+	go func() {
+		fmt.Scanln()
+		close(ch)
+	}()
+	for {
+		text := next()
+		fmt.Print(text)
+		time.Sleep(time.Millisecond * time.Duration(milliSecond))
+		fmt.Printf("\r%s\r", strings.Repeat(" ", displayWidth(text)))
+		select {
+		case <-ch:
+			return
+		default:
+		}
+	}
+}
+
+// displayWidth is the number of the columns of the text in terminal, a Chinese character takes two columns
+func displayWidth(text string) int {
+	width := 0
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) {
+			width += 2
+		} else {
+			width++
+		}
+	}
+	return width
+}
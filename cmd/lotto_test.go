@@ -1,7 +1,12 @@
 package cmd
 
 import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestLotto(t *testing.T) {
@@ -26,3 +31,122 @@ func TestLotto(t *testing.T) {
 	}
 
 }
+
+func TestDrawStudents(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	picked := drawStudents(r, labStudents, nil, 3)
+	seen := make(map[string]bool)
+	for _, s := range picked {
+		seen[s.Sno] = true
+	}
+	if len(picked) != 3 || len(seen) != 3 {
+		t.Errorf("Expected 3 different students, but got %v", picked)
+	}
+
+	// 张三 has been picked 9 times, so the weight is 0.1 against 1 of the others
+	counts := map[string]int{"2200001": 9}
+	times := 0
+	for i := 0; i < 2100; i++ {
+		if drawStudents(r, labStudents, counts, 1)[0].Sno == "2200001" {
+			times++
+		}
+	}
+	if times < 50 || times > 150 {
+		t.Errorf("Expected 张三 picked about 100 times, but got %d", times)
+	}
+}
+
+func TestPickSheets(t *testing.T) {
+	day := time.Date(2023, 9, 1, 10, 0, 0, 0, time.Local)
+	history := &pickHistory{Class: "c1", Picks: []pickRecord{
+		{Time: day.Add(time.Hour), Sno: "2200002", Name: "李四"},
+		{Time: day, Sno: "2200002", Name: "李四"},
+		{Time: day.AddDate(0, -3, 0), Sno: "2200001", Name: "张三"},
+	}}
+	if counts := pickCounts(history, day); !reflect.DeepEqual(counts, map[string]int{"2200002": 2}) {
+		t.Errorf("Expected the picks since the semester start, but got %v", counts)
+	}
+	sheets := pickSheets(labStudents, history)
+	expected := [][]string{{"张三", "2200001", "1", "2023-06-01 10:00"}, {"李四", "2200002", "2", "2023-09-01 11:00"}, {"赵六", "2200003", "0", ""}}
+	if !reflect.DeepEqual(sheets[0].Rows, expected) {
+		t.Errorf("Expected %v, but got %v", expected, sheets[0].Rows)
+	}
+	if sheets[1].Rows[0][0] != "2023-06-01 10:00" || len(sheets[1].Rows) != 3 {
+		t.Errorf("Expected the picks in time order, but got %v", sheets[1].Rows)
+	}
+}
+
+func TestDeriveDraw(t *testing.T) {
+	seed := "8f1c0b4e5a6d7c8b9a0f1e2d3c4b5a69788796a5b4c3d2e1f0a9b8c7d6e5f4a3"
+	if got := commitSeed("abc"); got != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		t.Errorf("Expected the SHA-256 of the seed, but got %s", got)
+	}
+	results := deriveDraw(seed, "20231001", 1, 11, 10)
+	seen := make(map[int64]bool)
+	for _, number := range results {
+		if number < 1 || number > 10 || seen[number] {
+			t.Errorf("Expected 10 different numbers from 1 to 10, but got %v", results)
+		}
+		seen[number] = true
+	}
+	if again := deriveDraw(seed, "20231001", 1, 11, 3); !reflect.DeepEqual(again, results[:3]) {
+		t.Errorf("Expected the same draw %v, but got %v", results[:3], again)
+	}
+	if other := deriveDraw(seed, "20231002", 1, 11, 10); reflect.DeepEqual(other, results) {
+		t.Errorf("Expected a different draw of another public value, but got %v", other)
+	}
+	// the end is excluded, the same as lotto
+	if err := checkDrawRange(1, 3, 3); err == nil {
+		t.Error("Expected an error of drawing 3 numbers from 1 and 2")
+	}
+	if err := checkDrawRange(1, 3, 2); err != nil {
+		t.Errorf("Expected no error of drawing 1 and 2, but got %v", err)
+	}
+}
+
+func TestDrawRounds(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "entries.txt")
+	os.WriteFile(file, []byte("# 名单\n张三,9\n李四\n\n王五\t1\n赵六，1\n"), 0644)
+	entries, err := readLottoEntries(file)
+	expected := []lottoEntry{{"张三", 9}, {"李四", 1}, {"王五", 1}, {"赵六", 1}}
+	if err != nil || !reflect.DeepEqual(entries, expected) {
+		t.Fatalf("Expected %v, but got %v, %v", expected, entries, err)
+	}
+	rounds, err := parseLottoRounds([]string{"二等奖=2", "一等奖=1"}, 1)
+	if err != nil || !reflect.DeepEqual(rounds, []lottoRound{{"二等奖", 2}, {"一等奖", 1}}) {
+		t.Fatalf("Expected 2 rounds, but got %v, %v", rounds, err)
+	}
+
+	r := rand.New(rand.NewSource(1))
+	times := 0
+	for i := 0; i < 1000; i++ {
+		winners, err := drawRounds(entries, rounds, func(_ int, pool []lottoEntry) int { return weightedEntry(r, pool) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen := make(map[string]bool)
+		for _, round := range winners {
+			for _, winner := range round {
+				if seen[winner.Name] {
+					t.Fatalf("Expected unique winners, but got %v", winners)
+				}
+				seen[winner.Name] = true
+			}
+		}
+		if winners[0][0].Name == "张三" {
+			times++
+		}
+	}
+	// 张三 has the weight 9 of the total 12
+	if times < 700 || times > 800 {
+		t.Errorf("Expected 张三 drawn first about 750 times, but got %d", times)
+	}
+	if _, err := drawRounds(entries, []lottoRound{{"一等奖", 5}}, nil); err == nil {
+		t.Error("Expected an error of more winners than the entries")
+	}
+
+	os.WriteFile(file, []byte("张三,0\n"), 0644)
+	if _, err := readLottoEntries(file); err == nil {
+		t.Error("Expected an error of the illegal weight")
+	}
+}
@@ -0,0 +1,266 @@
+/*
+Copyright © 2023 Lyu Lin <lvlin@whu.edu.cn>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	drawFile       string
+	drawCount      int
+	drawPublic     string
+	drawSeed       string
+	drawCommitment string
+)
+
+// lottoDraw is a committed draw, the seed is kept secret until the draw is revealed
+type lottoDraw struct {
+	Commitment string     `json:"commitment"`
+	Seed       string     `json:"seed"`
+	Start      int64      `json:"start"`
+	End        int64      `json:"end"`
+	Count      int        `json:"count"`
+	Committed  time.Time  `json:"committed"`
+	Public     string     `json:"public,omitempty"`
+	Results    []int64    `json:"results,omitempty"`
+	Revealed   *time.Time `json:"revealed,omitempty"`
+}
+
+// lottoCommitCmd represents the lotto commit command
+var lottoCommitCmd = &cobra.Command{
+	Use:   "commit",
+	Short: "生成秘密种子并公布其SHA-256承诺，用于可验证的抽奖.",
+	Long: `Draw the numbers verifiably in three steps:
+
+1. commit: a secret seed is generated, and its commitment, the SHA-256 of the seed in hex, is
+   published with the range and the number of the draws before the draw.
+2. reveal: a public value unknown before the commitment, e.g. a number given by a student, is
+   combined with the seed to draw the numbers, and then the seed is revealed.
+3. verify: anyone checks the seed against the commitment and draws the same numbers again.
+
+The k-th candidate (k = 0, 1, ...) is the first 8 bytes of SHA-256("<seed>:<public>:<k>") as a
+big-endian integer modulo the size of the range, the candidates biased by the modulo and the
+repeated numbers are skipped, so the draw can be recomputed even without this tool. The numbers
+are from start to end, excluding end, the same as the range of lotto.
+
+The draw is saved in 'lotto-draw.json' of the directory 'lotto.dir' until it is revealed.
+
+Example:
+
+$ mytools lotto commit -s 1 -e 50 -n 3
+$ mytools lotto reveal --public 20231001
+$ mytools lotto verify --commitment <sha256> --seed <seed> --public 20231001 -s 1 -e 50 -n 3`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkDrawRange(start, end, drawCount); err != nil {
+			return err
+		}
+		if draw, err := readLottoDraw(lottoDrawFile()); err == nil && draw.Results == nil {
+			return fmt.Errorf("the draw %s committed at %s is not revealed yet", draw.Commitment,
+				draw.Committed.Format("2006-01-02 15:04"))
+		}
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return err
+		}
+		seed := hex.EncodeToString(secret)
+		draw := &lottoDraw{Commitment: commitSeed(seed), Seed: seed, Start: start, End: end, Count: drawCount, Committed: time.Now()}
+		if err := writeLottoDraw(lottoDrawFile(), draw); err != nil {
+			return err
+		}
+		fmt.Println("Publish the commitment before the draw:")
+		fmt.Printf("Commitment: %s\nRange: %d-%d, %d numbers\n", draw.Commitment, draw.Start, draw.End, draw.Count)
+		return nil
+	},
+}
+
+// lottoRevealCmd represents the lotto reveal command
+var lottoRevealCmd = &cobra.Command{
+	Use:   "reveal",
+	Short: "结合公开值完成抽奖并公布种子.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if drawPublic == "" {
+			return fmt.Errorf("the public value is not given by --public")
+		}
+		draw, err := readLottoDraw(lottoDrawFile())
+		if err != nil {
+			return err
+		}
+		// the draw is revealed once, or another public value may be tried for a favored result
+		if draw.Results != nil && draw.Public != drawPublic {
+			return fmt.Errorf("the draw is revealed with the public value %s", draw.Public)
+		}
+		if draw.Results == nil {
+			draw.Public = drawPublic
+			draw.Results = deriveDraw(draw.Seed, draw.Public, draw.Start, draw.End, draw.Count)
+			now := time.Now()
+			draw.Revealed = &now
+			if err := writeLottoDraw(lottoDrawFile(), draw); err != nil {
+				return err
+			}
+		}
+		printLottoDraw(draw)
+		fmt.Printf("Verify: mytools lotto verify --commitment %s --seed %s --public %s -s %d -e %d -n %d\n",
+			draw.Commitment, draw.Seed, shellQuote(draw.Public), draw.Start, draw.End, draw.Count)
+		return nil
+	},
+}
+
+// lottoVerifyCmd represents the lotto verify command
+var lottoVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "校验种子与承诺是否一致，并重新计算抽奖结果.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if drawCommitment == "" || drawSeed == "" || drawPublic == "" {
+			return fmt.Errorf("the commitment, the seed and the public value should be given")
+		}
+		if err := checkDrawRange(start, end, drawCount); err != nil {
+			return err
+		}
+		if got := commitSeed(drawSeed); !strings.EqualFold(got, drawCommitment) {
+			return fmt.Errorf("the seed does not match the commitment, the SHA-256 of the seed is %s", got)
+		}
+		draw := &lottoDraw{Commitment: drawCommitment, Seed: drawSeed, Public: drawPublic, Start: start, End: end, Count: drawCount}
+		draw.Results = deriveDraw(draw.Seed, draw.Public, draw.Start, draw.End, draw.Count)
+		fmt.Println("The seed matches the commitment.")
+		printLottoDraw(draw)
+		return nil
+	},
+}
+
+func init() {
+	lottoCmd.AddCommand(lottoCommitCmd, lottoRevealCmd, lottoVerifyCmd)
+
+	for _, cmd := range []*cobra.Command{lottoCommitCmd, lottoVerifyCmd} {
+		cmd.Flags().Int64VarP(&start, "start", "s", 1, "The starting number.")
+		cmd.Flags().Int64VarP(&end, "end", "e", 100, "The ending number, excluded.")
+		cmd.Flags().IntVarP(&drawCount, "number", "n", 1, "The number of the draws without repeats.")
+	}
+	for _, cmd := range []*cobra.Command{lottoCommitCmd, lottoRevealCmd} {
+		cmd.Flags().StringVar(&drawFile, "file", "", "the file of the draw, default is lotto-draw.json of lotto.dir")
+	}
+	lottoRevealCmd.Flags().StringVar(&drawPublic, "public", "", "the public value combined with the seed")
+	lottoVerifyCmd.Flags().StringVar(&drawPublic, "public", "", "the public value of the draw")
+	lottoVerifyCmd.Flags().StringVar(&drawSeed, "seed", "", "the revealed seed")
+	lottoVerifyCmd.Flags().StringVar(&drawCommitment, "commitment", "", "the published commitment")
+}
+
+func checkDrawRange(start, end int64, count int) error {
+	if start >= end || start < 0 {
+		return fmt.Errorf("start number must be less than end number and must be greater or equal then zero, but start = %d, end = %d",
+			start, end)
+	}
+	if count < 1 || uint64(count) > uint64(end-start) {
+		return fmt.Errorf("the number of the draws should be between 1 and %d, but got %d", end-start, count)
+	}
+	return nil
+}
+
+// commitSeed returns the commitment of the seed, the SHA-256 of the seed in hex
+func commitSeed(seed string) string {
+	sum := sha256.Sum256([]byte(seed))
+	return hex.EncodeToString(sum[:])
+}
+
+// deriveDraw draws count different numbers from start to end, excluding end, by the seed and the public value
+func deriveDraw(seed, public string, start, end int64, count int) []int64 {
+	size := uint64(end - start)
+	// the candidates greater than limit are skipped to avoid the modulo bias
+	limit := math.MaxUint64 - (math.MaxUint64%size+1)%size
+	seen := make(map[int64]bool)
+	var results []int64
+	for k := 0; len(results) < count; k++ {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%s:%d", seed, public, k)))
+		candidate := binary.BigEndian.Uint64(sum[:8])
+		if candidate > limit {
+			continue
+		}
+		number := start + int64(candidate%size)
+		if !seen[number] {
+			seen[number] = true
+			results = append(results, number)
+		}
+	}
+	return results
+}
+
+func printLottoDraw(draw *lottoDraw) {
+	fmt.Printf("Commitment: %s\nSeed: %s\nPublic: %s\nRange: %d-%d\n", draw.Commitment, draw.Seed, draw.Public, draw.Start, draw.End)
+	for i, number := range draw.Results {
+		fmt.Printf("%d. %d\n", i+1, number)
+	}
+}
+
+// shellQuote quotes the value for the shell if it has the special characters
+func shellQuote(s string) string {
+	if strings.ContainsAny(s, " \t'\"$`\\|&;<>()*?") {
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	}
+	return s
+}
+
+func lottoDrawFile() string {
+	if drawFile != "" {
+		return drawFile
+	}
+	dir := viper.GetString("lotto.dir")
+	if dir == "" {
+		dir = "."
+	}
+	return filepath.Join(dir, "lotto-draw.json")
+}
+
+func readLottoDraw(file string) (*lottoDraw, error) {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no draw is committed in %s, run 'mytools lotto commit' first", file)
+	} else if err != nil {
+		return nil, err
+	}
+	draw := &lottoDraw{}
+	if err := json.Unmarshal(data, draw); err != nil {
+		return nil, fmt.Errorf("broken draw %s: %w", file, err)
+	}
+	return draw, nil
+}
+
+func writeLottoDraw(file string, draw *lottoDraw) error {
+	data, err := json.MarshalIndent(draw, "", "  ")
+	if err != nil {
+		return err
+	}
+	// the seed is a secret until revealed
+	return os.WriteFile(file, data, 0600)
+}
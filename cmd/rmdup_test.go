@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"reflect"
 	"testing"
 	"time"
 )
@@ -134,3 +135,16 @@ func TestRmdup1(t *testing.T) {
 		})
 	}
 }
+
+func TestIdenticalAttachments(t *testing.T) {
+	emails := []EmailInfo{
+		{SeqNum: 1, From: "a@example.com", Attachments: []string{"a.doc", "b.doc"}, AttachmentHashes: []string{"h1", "h2"}},
+		{SeqNum: 2, From: "b@example.com", Attachments: []string{"c.doc"}, AttachmentHashes: []string{"h1"}},
+		{SeqNum: 3, From: "c@example.com", Attachments: []string{"d.doc", "e.doc"}, AttachmentHashes: []string{"h3", "h3"}},
+		{SeqNum: 4, From: "d@example.com", Attachments: []string{"f.doc"}},
+	}
+	want := [][]string{{"1 a@example.com a.doc", "2 b@example.com c.doc"}}
+	if got := identicalAttachments(emails); !reflect.DeepEqual(got, want) {
+		t.Errorf("identicalAttachments() = %v, want %v", got, want)
+	}
+}
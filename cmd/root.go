@@ -74,6 +74,9 @@ func init() {
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "",
 		"config file (default is $HOME/.mytools.yaml)")
+	// the registry is read by student, lab, course and lotto pick besides roster
+	rootCmd.PersistentFlags().String("registry", "", "the roster registry file, default is roster.file or roster.json next to the config file")
+	viper.BindPFlag("roster.file", rootCmd.PersistentFlags().Lookup("registry"))
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
@@ -0,0 +1,455 @@
+/*
+Copyright © 2023 Lyu Lin <lvlin@whu.edu.cn>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jackeylu/mytools/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	rosterFile      string
+	rosterClass     string
+	rosterCourse    string
+	rosterOverwrite bool
+)
+
+// rosterRecord is a student in the registry
+type rosterRecord struct {
+	Name  string `json:"name"`
+	No    string `json:"no"`
+	Class string `json:"class"`
+	Grade string `json:"grade,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// rosterRegistry keeps the students of all the classes, and the classes of the courses
+type rosterRegistry struct {
+	Students []rosterRecord `json:"students"`
+	// Courses are the classes keyed by the course id
+	Courses map[string][]string `json:"courses,omitempty"`
+}
+
+// rosterConflict is an imported record with the same sno but a different name
+type rosterConflict struct {
+	Existing rosterRecord
+	Incoming rosterRecord
+}
+
+func (c rosterConflict) String() string {
+	return fmt.Sprintf("%s: %s(%s) in the registry, %s(%s) imported",
+		c.Existing.No, c.Existing.Name, c.Existing.Class, c.Incoming.Name, c.Incoming.Class)
+}
+
+// maxTitleRows is the number of the rows searched for the header
+const maxTitleRows = 20
+
+// rosterColumns are the candidate headers of the fields of a record
+var rosterColumns = map[string][]string{
+	"name":  {"姓名", "name"},
+	"no":    {"学号", "sno", "no", "学籍号"},
+	"class": {"班级", "class", "行政班"},
+	"grade": {"年级", "grade"},
+	"email": {"邮箱", "电子邮箱", "email", "e-mail"},
+}
+
+// rosterCmd represents the roster command
+var rosterCmd = &cobra.Command{
+	Use:   "roster",
+	Short: "将各种格式的学生名单导入统一的学生名册，供student、lab和course命令使用.",
+	Long: `Import the rosters of xlsx or csv files into one registry, the columns are mapped by the headers,
+such as 姓名/name, 学号/sno, 班级/class, 年级/grade and 邮箱/email.
+
+The class lists exported from the academic affairs system as ".xls" are html tables or old BIFF
+files, their real format is detected by the content, and the title rows above the header are skipped.
+
+The registry is the file given by --registry of any command or roster.file, default is roster.json
+in the directory of the configuration file, e.g. $HOME/roster.json. It is read:
+  - by student, when neither --dataset nor lab.all-student is given
+  - by lab, when lab.class.<coursename> is not configured, the coursename is taken as a class
+  - by course, when a class of course.<id>.classes is not a .xlsx or .csv file, or the course has no classes
+
+Example:
+
+$ mytools roster import -f 信安一班.xlsx --class 信安一班 --course php2023
+$ mytools roster list --course php2023`,
+}
+
+var rosterImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "导入xlsx、xls、html或csv格式的学生名单.",
+	Long: `Import a roster into the registry. The class column may be absent when --class is given.
+A student with the same sno but a different name is a conflict, nothing is imported if any conflict
+is found, unless --overwrite is given.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if rosterFile == "" {
+			return fmt.Errorf("the roster file is not given by --file")
+		}
+		records, err := readRosterFile(rosterFile, rosterClass)
+		if err != nil {
+			return err
+		}
+		registry, err := loadRegistry()
+		if err != nil {
+			return err
+		}
+		added, updated, conflicts := registry.merge(records, rosterOverwrite)
+		if len(conflicts) > 0 {
+			fmt.Fprintln(os.Stderr, "Conflicts:")
+			for _, c := range conflicts {
+				fmt.Fprintln(os.Stderr, c)
+			}
+			if !rosterOverwrite {
+				return fmt.Errorf("%d conflicts found, nothing is imported, use --overwrite to take the imported records", len(conflicts))
+			}
+		}
+		if rosterCourse != "" {
+			registry.addCourseClasses(rosterCourse, records)
+		}
+		if err := saveRegistry(registry); err != nil {
+			return err
+		}
+		fmt.Printf("%d added, %d updated, the registry is saved in %s\n", added, updated, registryFile())
+		return nil
+	},
+}
+
+var rosterListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "列出名册中的学生，可以按班级或课程筛选.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registry, err := loadRegistry()
+		if err != nil {
+			return err
+		}
+		classes := registry.classes()
+		if rosterCourse != "" {
+			classes = registry.Courses[rosterCourse]
+		}
+		if rosterClass != "" {
+			classes = []string{rosterClass}
+		}
+		for _, class := range classes {
+			records := registry.class(class)
+			fmt.Printf("%s(%d)\n", class, len(records))
+			for _, r := range records {
+				fmt.Printf("  %s\t%s\t%s\t%s\n", r.No, r.Name, r.Grade, r.Email)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rosterCmd)
+	rosterCmd.AddCommand(rosterImportCmd, rosterListCmd)
+
+	rosterCmd.PersistentFlags().StringVar(&rosterClass, "class", "", "the class of the students")
+	rosterCmd.PersistentFlags().StringVar(&rosterCourse, "course", "", "the course id taking the classes")
+	rosterImportCmd.Flags().StringVarP(&rosterFile, "file", "f", "", "the xlsx or csv roster file")
+	rosterImportCmd.Flags().BoolVar(&rosterOverwrite, "overwrite", false, "take the imported records on conflicts")
+}
+
+// registryFile returns the registry given by --registry or roster.file, else roster.json next to the
+// configuration file, so the registry is the same wherever the commands run.
+func registryFile() string {
+	if file := viper.GetString("roster.file"); file != "" {
+		return file
+	}
+	if config := viper.ConfigFileUsed(); config != "" {
+		return filepath.Join(filepath.Dir(config), "roster.json")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, "roster.json")
+	}
+	return "roster.json"
+}
+
+// loadRegistry reads the registry, an empty registry is returned if the file does not exist
+func loadRegistry() (*rosterRegistry, error) {
+	registry := &rosterRegistry{}
+	data, err := os.ReadFile(registryFile())
+	if os.IsNotExist(err) {
+		return registry, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, registry); err != nil {
+		return nil, fmt.Errorf("broken registry %s: %w", registryFile(), err)
+	}
+	return registry, nil
+}
+
+func saveRegistry(registry *rosterRegistry) error {
+	sort.SliceStable(registry.Students, func(i, j int) bool {
+		a, b := registry.Students[i], registry.Students[j]
+		if a.Class != b.Class {
+			return a.Class < b.Class
+		}
+		return a.No < b.No
+	})
+	data, err := json.MarshalIndent(registry, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(registryFile(), data, 0644)
+}
+
+// merge adds the new records and updates the existing ones with the non-empty fields of the records,
+// the records conflicting with the registry or with each other are taken only if overwrite.
+func (r *rosterRegistry) merge(records []rosterRecord, overwrite bool) (added, updated int, conflicts []rosterConflict) {
+	index := make(map[string]int)
+	for i, s := range r.Students {
+		index[s.No] = i
+	}
+	for _, record := range records {
+		i, ok := index[record.No]
+		if !ok {
+			index[record.No] = len(r.Students)
+			r.Students = append(r.Students, record)
+			added++
+			continue
+		}
+		existing := &r.Students[i]
+		if existing.Name != record.Name {
+			conflicts = append(conflicts, rosterConflict{Existing: *existing, Incoming: record})
+			if !overwrite {
+				continue
+			}
+			existing.Name = record.Name
+		}
+		for _, field := range []struct {
+			dst *string
+			src string
+		}{
+			{&existing.Class, record.Class}, {&existing.Grade, record.Grade}, {&existing.Email, record.Email},
+		} {
+			if field.src != "" {
+				*field.dst = field.src
+			}
+		}
+		updated++
+	}
+	return added, updated, conflicts
+}
+
+// addCourseClasses records the classes of the records as taken by the course
+func (r *rosterRegistry) addCourseClasses(course string, records []rosterRecord) {
+	if r.Courses == nil {
+		r.Courses = make(map[string][]string)
+	}
+	for _, record := range records {
+		if !containsString(r.Courses[course], record.Class) {
+			r.Courses[course] = append(r.Courses[course], record.Class)
+		}
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// classes returns all the classes in the registry, sorted by name
+func (r *rosterRegistry) classes() []string {
+	var classes []string
+	for _, s := range r.Students {
+		if !containsString(classes, s.Class) {
+			classes = append(classes, s.Class)
+		}
+	}
+	sort.Strings(classes)
+	return classes
+}
+
+// class returns the students of the class
+func (r *rosterRegistry) class(name string) []rosterRecord {
+	var records []rosterRecord
+	for _, s := range r.Students {
+		if s.Class == name {
+			records = append(records, s)
+		}
+	}
+	return records
+}
+
+// students returns all the students as the records of the student command
+func (r *rosterRegistry) students() []Student {
+	students := make([]Student, len(r.Students))
+	for i, s := range r.Students {
+		students[i] = Student{Name: s.Name, No: s.No, Class: s.Class, Grade: s.Grade}
+	}
+	return students
+}
+
+// isRosterFile tells whether the namelist is a file, or a class of the registry
+func isRosterFile(namelist string) bool {
+	switch strings.ToLower(filepath.Ext(namelist)) {
+	case ".xlsx", ".xlsm", ".xls", ".csv":
+		return true
+	}
+	return false
+}
+
+// readClassRoster reads the namelist file, or the class of the registry when it is not a file.
+// The xlsx namelist has the columns name, sno and an optional email, the other formats, such as the
+// csv files and the class lists of the academic affairs system, are mapped by the headers.
+func readClassRoster(namelist string) ([]CourseStudent, error) {
+	var records []rosterRecord
+	if isRosterFile(namelist) {
+		format, err := util.DetectTableFormat(namelist)
+		if err != nil {
+			return nil, err
+		}
+		if format == util.FormatXLSX {
+			return ReadNameList(namelist)
+		}
+		if records, _, err = readRosterRecords(namelist); err != nil {
+			return nil, err
+		}
+	} else {
+		registry, err := loadRegistry()
+		if err != nil {
+			return nil, err
+		}
+		records = registry.class(namelist)
+		if len(records) == 0 {
+			return nil, fmt.Errorf("class %s is not found in the registry %s", namelist, registryFile())
+		}
+	}
+	students := make([]CourseStudent, len(records))
+	for i, s := range records {
+		students[i] = CourseStudent{Name: s.Name, Sno: s.No, Class: s.Class, Email: s.Email}
+	}
+	return students, nil
+}
+
+// readRosterFile reads the records of a roster, and the class is the given one when the class column is absent.
+func readRosterFile(file string, class string) ([]rosterRecord, error) {
+	records, hasClass, err := readRosterRecords(file)
+	if records == nil && err != nil {
+		return nil, err
+	}
+	if !hasClass && class == "" {
+		return nil, fmt.Errorf("%s has no class column, the class should be given by --class", file)
+	}
+	if class != "" {
+		for i := range records {
+			records[i].Class = class
+		}
+	}
+	return records, err
+}
+
+// readRosterRecords reads the records of a roster, the columns are mapped by the headers found below
+// the title rows, and tells whether the roster has the class column.
+func readRosterRecords(file string) ([]rosterRecord, bool, error) {
+	rows, err := readRosterTable(file)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(rows) > 0 && len(rows[0]) > 0 {
+		// the csv files saved by excel start with a BOM
+		rows[0][0] = strings.TrimPrefix(rows[0][0], "\ufeff")
+	}
+	// the header is the first row with the name and the sno columns, below the title rows
+	header := -1
+	columns := make(map[string]int)
+	for i := 0; i < len(rows) && i < maxTitleRows && header == -1; i++ {
+		for field, names := range rosterColumns {
+			columns[field] = util.FindColumn(rows[i], names...)
+		}
+		if columns["name"] != -1 && columns["no"] != -1 {
+			header = i
+		}
+	}
+	if header == -1 {
+		return nil, false, fmt.Errorf("%s has no header with the name and sno columns, such as 姓名 and 学号", file)
+	}
+	cell := func(row []string, field string) string {
+		if i := columns[field]; i >= 0 && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	var records []rosterRecord
+	var errs []error
+	for i, row := range rows[header+1:] {
+		record := rosterRecord{Name: cell(row, "name"), No: cell(row, "no"), Class: cell(row, "class"),
+			Grade: cell(row, "grade"), Email: cell(row, "email")}
+		if record.Name == "" && record.No == "" {
+			continue
+		}
+		if record.Name == "" || record.No == "" {
+			errs = append(errs, fmt.Errorf("%s: row %d has no name or sno", file, header+i+2))
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, columns["class"] != -1, errors.Join(errs...)
+}
+
+// readRosterTable reads all the rows of the first sheet of a roster, the format is detected by the content
+// since the exports of the academic affairs system named ".xls" are html tables or BIFF files.
+func readRosterTable(file string) ([][]string, error) {
+	format, err := util.DetectTableFormat(file)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case util.FormatXLS:
+		return util.ReadXLSFile(file)
+	case util.FormatHTML:
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return util.ReadHTMLTable(f)
+	case util.FormatCSV:
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return util.ReadCSVTable(f)
+	}
+	var rows [][]string
+	err = util.ReadExcelFile(file, func(_ int, row []string) error {
+		rows = append(rows, row)
+		return nil
+	}, false)
+	return rows, err
+}
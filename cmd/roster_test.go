@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestReadRosterFile(t *testing.T) {
+	csvFile := filepath.Join(t.TempDir(), "信安一班.csv")
+	content := "\ufeff序号,学号,姓名,邮箱\n1,2200001,张三,zs@example.com\n2,2200002,李四,\n,,,\n"
+	if err := os.WriteFile(csvFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readRosterFile(csvFile, ""); err == nil {
+		t.Error("Expected an error of the missing class")
+	}
+	records, err := readRosterFile(csvFile, "信安一班")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []rosterRecord{
+		{Name: "张三", No: "2200001", Class: "信安一班", Email: "zs@example.com"},
+		{Name: "李四", No: "2200002", Class: "信安一班"},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("Expected %v, but got %v", expected, records)
+	}
+}
+
+func TestRegistryMerge(t *testing.T) {
+	registry := &rosterRegistry{Students: []rosterRecord{{Name: "张三", No: "2200001", Class: "信安一班"}}}
+	added, updated, conflicts := registry.merge([]rosterRecord{
+		{Name: "张三", No: "2200001", Class: "信安一班", Email: "zs@example.com"},
+		{Name: "李四", No: "2200002", Class: "信安一班"},
+		{Name: "李思", No: "2200002", Class: "信安二班"},
+	}, false)
+	if added != 1 || updated != 1 || len(conflicts) != 1 {
+		t.Fatalf("Expected 1 added, 1 updated and 1 conflict, but got %d %d %v", added, updated, conflicts)
+	}
+	if conflicts[0].String() != "2200002: 李四(信安一班) in the registry, 李思(信安二班) imported" {
+		t.Errorf("Unexpected conflict %s", conflicts[0])
+	}
+	if registry.Students[0].Email != "zs@example.com" || registry.Students[1].Name != "李四" {
+		t.Errorf("Unexpected registry %v", registry.Students)
+	}
+
+	registry.merge([]rosterRecord{{Name: "李思", No: "2200002", Class: "信安二班"}}, true)
+	if registry.Students[1].Name != "李思" || registry.Students[1].Class != "信安二班" {
+		t.Errorf("Expected the overwritten record, but got %v", registry.Students[1])
+	}
+}
+
+func TestReadClassRoster(t *testing.T) {
+	viper.Set("roster.file", filepath.Join(t.TempDir(), "roster.json"))
+	defer viper.Set("roster.file", "")
+
+	registry := &rosterRegistry{}
+	records := []rosterRecord{
+		{Name: "张三", No: "2200001", Class: "信安一班", Email: "zs@example.com"},
+		{Name: "赵六", No: "2200003", Class: "信安二班"},
+	}
+	registry.merge(records, false)
+	registry.addCourseClasses("php2023", records)
+	if err := saveRegistry(registry); err != nil {
+		t.Fatal(err)
+	}
+
+	students, err := readClassRoster("信安一班")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(students, []CourseStudent{{Name: "张三", Sno: "2200001", Class: "信安一班", Email: "zs@example.com"}}) {
+		t.Errorf("Unexpected students %v", students)
+	}
+	if _, err := readClassRoster("信安三班"); err == nil {
+		t.Error("Expected an error of the unknown class")
+	}
+
+	course, err := loadCourse("php2023")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(course.CourseStudents) != 2 || course.CourseStudents[1].Class != "信安二班" {
+		t.Errorf("Unexpected course students %v", course.CourseStudents)
+	}
+}
+
+func TestReadClassRosterCSV(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "class1.csv")
+	os.WriteFile(file, []byte("\ufeff姓名,学号,邮箱\n张三,2200001,zs@example.com\n李四,2200002,\n"), 0644)
+	students, err := readClassRoster(file)
+	expected := []CourseStudent{{Name: "张三", Sno: "2200001", Email: "zs@example.com"}, {Name: "李四", Sno: "2200002"}}
+	if err != nil || !reflect.DeepEqual(students, expected) {
+		t.Errorf("Expected %v, but got %v, %v", expected, students, err)
+	}
+}
+
+func TestReadRosterFileHTML(t *testing.T) {
+	xlsFile := filepath.Join(t.TempDir(), "班级名单.xls")
+	page := `<html><meta charset="utf-8"><table>
+<tr><td colspan="5">武汉大学2022级学生名单</td></tr>
+<tr><td colspan="5">导出时间：2023-09-01</td></tr>
+<tr><td>序号</td><td>学号</td><td>姓名</td><td>班级</td><td>年级</td><td>邮箱</td></tr>
+<tr><td>1</td><td>2200001</td><td>张三</td><td>信安一班</td><td>2022</td><td>zs@example.com</td></tr>
+<tr><td>2</td><td>2200003</td><td>赵六</td><td>信安二班</td><td>2022</td><td></td></tr>
+</table></html>`
+	if err := os.WriteFile(xlsFile, []byte(page), 0644); err != nil {
+		t.Fatal(err)
+	}
+	records, err := readRosterFile(xlsFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []rosterRecord{
+		{Name: "张三", No: "2200001", Class: "信安一班", Grade: "2022", Email: "zs@example.com"},
+		{Name: "赵六", No: "2200003", Class: "信安二班", Grade: "2022"},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("Expected %v, but got %v", expected, records)
+	}
+}
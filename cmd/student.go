@@ -22,11 +22,10 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"strings"
 
-	"github.com/atotto/clipboard"
 	"github.com/jackeylu/mytools/util"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -39,6 +38,8 @@ var (
 	keys []string
 	// showing the found result with name first or NO. first?
 	reverse bool
+	// only the students of the class are searched
+	studentClass string
 )
 
 type Student struct {
@@ -55,10 +56,32 @@ func (s Student) String() string {
 // studentCmd represents the student command
 var studentCmd = &cobra.Command{
 	Use:   "student",
-	Short: "根据学生的完整姓名或学号进行查询.",
-	Long:  `在指定的excel文件中查找学生信息，如果找到将会输出学生信息，包括姓名、学号、班级、年级.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		findStudent(excelFile, keys)
+	Short: "根据学生的姓名、学号或拼音进行查询.",
+	Long: `在指定的excel文件中查找学生信息，如果找到将会输出学生信息，包括姓名、学号、班级、年级.
+
+每个关键字都会被查询，支持完整或部分的姓名和学号、学号前缀、全拼和拼音首字母(如"zs"查找张三)，
+以及一个字符以内的输入错误。结果按匹配程度排序，多个学生匹配时需要选择一个，再将标签复制到剪贴板。
+
+标签的格式由text/template定义，可用字段为.Name、.No、.Class和.Grade，标签依次尝试输出到系统剪贴板、
+OSC 52终端剪贴板(用于SSH远程会话)和标准输出，前一个失败时自动使用下一个:
+
+student:
+  tag: "{{.No}}_{{.Name}}_{{.Class}}"
+  targets: [clipboard, osc52, stdout]
+
+$ mytools student -k zs,2200001,李四
+
+批量查询时，从文件或标准输入逐行读取学号或姓名，只接受唯一的精确匹配，输出表格或xlsx文件，并列出未匹配的关键字。
+也可以将已有表格中的学号或姓名列替换为姓名、学号、班级、年级四列，未匹配的行在姓名列中标记为"未匹配: 关键字"，
+默认保存为原文件旁的"<文件名>-filled.xlsx"，原文件保持不变。
+
+$ pbpaste | mytools student -f - -o students.xlsx
+$ mytools student --fill scores.xlsx --column 学号`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if keysFile != "" || fillFile != "" {
+			return runBatchLookup(excelFile)
+		}
+		return findStudent(excelFile, keys)
 	},
 }
 
@@ -68,31 +91,101 @@ func init() {
 	studentCmd.Flags().StringVarP(&excelFile, "dataset", "d", "", "the dataset file")
 	studentCmd.Flags().StringSliceVarP(&keys, "keys", "k", []string{}, "the key text of students")
 	studentCmd.Flags().BoolVarP(&reverse, "reverse", "r", false, "student no first or name first?")
+	studentCmd.Flags().StringVar(&studentClass, "class", "", "only search the students of the class")
+	studentCmd.Flags().StringVar(&tagFormat, "tag", "", "the text/template of the tag, e.g. '{{.No}}_{{.Name}}_{{.Class}}', default is student.tag")
+	studentCmd.Flags().StringSliceVar(&tagTargets, "to", nil, "the targets of the tag tried in order: clipboard, osc52, stdout")
+	studentCmd.Flags().StringVarP(&keysFile, "file", "f", "", "look up the keys of the file, one key per line, '-' for stdin")
+	studentCmd.Flags().StringVarP(&lookupOutput, "output", "o", "", "save the lookup result into the xlsx file")
+	studentCmd.Flags().StringVar(&fillFile, "fill", "", "replace the key column of the spreadsheet with the lookup columns")
+	studentCmd.Flags().StringVar(&keyColumn, "column", "", "the header of the key column of --fill")
 }
 
-func findStudent(excelFile string, keys []string) {
-	// check if the dataset file exists
+var (
+	// ErrEmptyDataset tells neither --dataset nor lab.all-student is given
+	ErrEmptyDataset = errors.New("dataset is empty, given by --dataset, lab.all-student or the roster registry")
+	// ErrEmptyKeys tells no key is given by --keys
+	ErrEmptyKeys = errors.New("keys is empty")
+)
+
+// loadStudents reads the dataset file given by --dataset or lab.all-student, or the registry
+// if neither is given, and keeps the students of --class if given.
+func loadStudents(excelFile string) ([]Student, error) {
 	if excelFile == "" {
 		excelFile = viper.GetString("lab.all-student")
 	}
-	if excelFile == "" {
-		panic("dataset is empty")
+	var students []Student
+	if excelFile != "" {
+		fmt.Fprintln(os.Stderr, "Using namelist file:", excelFile)
+		lines, err := readStudents(excelFile)
+		if err != nil {
+			return nil, err
+		}
+		students = lines
+	} else {
+		if _, err := os.Stat(registryFile()); err != nil {
+			return nil, ErrEmptyDataset
+		}
+		fmt.Fprintln(os.Stderr, "Using registry file:", registryFile())
+		registry, err := loadRegistry()
+		if err != nil {
+			return nil, err
+		}
+		students = registry.students()
 	}
+	if studentClass == "" {
+		return students, nil
+	}
+	var inClass []Student
+	for _, s := range students {
+		if s.Class == studentClass {
+			inClass = append(inClass, s)
+		}
+	}
+	return inClass, nil
+}
+
+func findStudent(excelFile string, keys []string) error {
 	if len(keys) == 0 {
-		panic("keys is empty")
+		return ErrEmptyKeys
 	}
 	fmt.Println(keys)
-	findStudentByKeys(excelFile, keys)
+	return findStudentByKeys(excelFile, keys)
+}
+
+func findStudentByKeys(excelFile string, keys []string) error {
+	lines, err := loadStudents(excelFile)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		matches := searchStudents(lines, key)
+		if len(matches) == 0 {
+			fmt.Printf("Can not find any student with keyword: %s\n", key)
+			continue
+		}
+		student, ok, err := pickStudent(key, matches)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if err := copyStudentTag(student); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
-func findStudentByKeys(excelFile string, keys []string) {
-	fmt.Fprintln(os.Stderr, "Using namelist file:", excelFile)
-	// read the csvfile into slice of Student
+// readStudents reads the students with the columns name, no, class and grade,
+// every malformed row is reported as an *ErrRosterMalformed in the joined error.
+func readStudents(excelFile string) ([]Student, error) {
 	lines := make([]Student, 0)
-	util.ReadExcelFile(excelFile, func(_ int, line []string) error {
+	var malformed []error
+	err := util.ReadExcelFile(excelFile, func(i int, line []string) error {
 		if len(line) != 4 {
-			panic(fmt.Errorf("error reading namelist fields:%v, expected four columns but not matched",
-				line))
+			// the header is ignored
+			malformed = append(malformed, &ErrRosterMalformed{File: excelFile, Row: i + 2, Got: len(line), Expected: "4"})
+			return nil
 		}
 		lines = append(lines, Student{
 			Name:  line[0],
@@ -102,32 +195,39 @@ func findStudentByKeys(excelFile string, keys []string) {
 		})
 		return nil
 	}, true)
-	// find the student by key
-	if !findStudentByKeyInSlice(lines, strings.TrimSpace(keys[len(keys)-1])) {
-		fmt.Printf("Can not find any student with keyword: %s\n", keys[len(keys)-1])
+	if err != nil {
+		return nil, err
 	}
+	if len(malformed) > 0 {
+		return nil, errors.Join(malformed...)
+	}
+	return lines, nil
 }
 
-func findStudentByKeyInSlice(lines []Student, key string) bool {
-	var found bool = false
-	for _, line := range lines {
-		if line.No == key || line.Name == key {
-			found = true
-			fmt.Printf("student %s found with result: %v\n", line.Name, line)
-			var err error
-			var tag string
-			if !reverse {
-				tag = fmt.Sprintf("%s-%s", line.Name, line.No)
-			} else {
-				tag = fmt.Sprintf("%s-%s", line.No, line.Name)
-			}
-			err = clipboard.WriteAll(tag)
-			if err != nil {
-				fmt.Println("failed to copy the tag to clipboard:", err)
-			} else {
-				fmt.Printf("The tag %s is copied to clipboard.\n", tag)
-			}
-		}
+// copyStudentTag outputs the tag of the student to the first working target, e.g. "张三-2200001"
+func copyStudentTag(student Student) error {
+	fmt.Printf("student %s found with result: %v\n", student.Name, student)
+	tmpl, err := tagTemplate()
+	if err != nil {
+		return err
+	}
+	tag, err := studentTag(tmpl, student)
+	if err != nil {
+		return err
+	}
+	targets := tagTargets
+	if len(targets) == 0 {
+		targets = viper.GetStringSlice("student.targets")
+	}
+	if len(targets) == 0 {
+		targets = defaultTagTargets
+	}
+	target, err := writeTag(tag, targets)
+	if err != nil {
+		return fmt.Errorf("failed to output the tag %s: %w", tag, err)
+	}
+	if target != tagTargetStdout {
+		fmt.Printf("The tag %s is copied by %s.\n", tag, target)
 	}
-	return found
+	return nil
 }
@@ -0,0 +1,238 @@
+/*
+Copyright © 2023 Lyu Lin <lvlin@whu.edu.cn>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jackeylu/mytools/util"
+	"github.com/xuri/excelize/v2"
+)
+
+var (
+	// the file of the keys, one key per line, "-" for stdin
+	keysFile string
+	// the xlsx file to save the lookup result
+	lookupOutput string
+	// the spreadsheet whose key column is replaced by the lookup columns
+	fillFile string
+	// the header of the key column of fillFile
+	keyColumn string
+)
+
+// lookupHeaders are the columns of the full Student record
+var lookupHeaders = []string{"姓名", "学号", "班级", "年级"}
+
+// unmatchedPrefix marks the unmatched key in the name cell of a filled spreadsheet
+const unmatchedPrefix = "未匹配: "
+
+// studentLookup is the result of looking up a key
+type studentLookup struct {
+	Key     string
+	Student Student
+	Found   bool
+}
+
+// lookupStudent returns the only student exactly matching the key, the fuzzy matches are not
+// taken in batch lookup since nobody picks among them.
+func lookupStudent(students []Student, key string) (Student, bool) {
+	matches := searchStudents(students, key)
+	if len(matches) == 0 || matches[0].Score != exactScore || len(matches) > 1 && matches[1].Score == exactScore {
+		return Student{}, false
+	}
+	return matches[0].Student, true
+}
+
+func lookupStudents(students []Student, keys []string) []studentLookup {
+	results := make([]studentLookup, len(keys))
+	for i, key := range keys {
+		results[i].Key = key
+		results[i].Student, results[i].Found = lookupStudent(students, key)
+	}
+	return results
+}
+
+// readKeys reads the non-empty lines as keys
+func readKeys(r io.Reader) ([]string, error) {
+	var keys []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if key := strings.TrimSpace(scanner.Text()); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys, scanner.Err()
+}
+
+func readKeysFile(file string) ([]string, error) {
+	if file == "-" {
+		return readKeys(os.Stdin)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readKeys(f)
+}
+
+// runBatchLookup looks up the keys of the keys file, or fills the lookup columns into the spreadsheet
+func runBatchLookup(excelFile string) error {
+	students, err := loadStudents(excelFile)
+	if err != nil {
+		return err
+	}
+	if fillFile == "" {
+		return batchLookup(students)
+	}
+	if keyColumn == "" {
+		return fmt.Errorf("the key column is not given by --column")
+	}
+	output := lookupOutput
+	if output == "" {
+		output = filledFileName(fillFile)
+	}
+	unmatched, err := fillLookupColumns(students, fillFile, keyColumn, output)
+	if err != nil {
+		return err
+	}
+	fmt.Println("The filled spreadsheet is saved in", output)
+	if len(unmatched) > 0 {
+		fmt.Println("Unmatched:")
+		for _, key := range unmatched {
+			fmt.Println(key)
+		}
+	}
+	return nil
+}
+
+// batchLookup looks up the keys of the keys file, and prints them as a table or saves them into an xlsx file
+func batchLookup(students []Student) error {
+	keys, err := readKeysFile(keysFile)
+	if err != nil {
+		return err
+	}
+	results := lookupStudents(students, keys)
+	var rows [][]string
+	var unmatched []string
+	for _, r := range results {
+		if !r.Found {
+			unmatched = append(unmatched, r.Key)
+			continue
+		}
+		rows = append(rows, []string{r.Key, r.Student.Name, r.Student.No, r.Student.Class, r.Student.Grade})
+	}
+	headers := append([]string{"关键字"}, lookupHeaders...)
+	if lookupOutput != "" {
+		var missing [][]string
+		for _, key := range unmatched {
+			missing = append(missing, []string{key})
+		}
+		if err := util.WriteExcelSheets(lookupOutput, []util.Sheet{
+			{Name: "查询结果", Headers: headers, Rows: rows},
+			{Name: "未匹配", Headers: []string{"关键字"}, Rows: missing},
+		}); err != nil {
+			return err
+		}
+		fmt.Printf("%d found, %d unmatched, the result is saved in %s\n", len(rows), len(unmatched), lookupOutput)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+	if len(unmatched) > 0 {
+		fmt.Println("Unmatched:")
+		for _, key := range unmatched {
+			fmt.Println(key)
+		}
+	}
+	return nil
+}
+
+// filledFileName returns the default output of --fill next to the spreadsheet, e.g. "scores-filled.xlsx",
+// so that the original one is kept.
+func filledFileName(excelFile string) string {
+	return strings.TrimSuffix(excelFile, filepath.Ext(excelFile)) + "-filled.xlsx"
+}
+
+// fillLookupColumns replaces the key column of the first sheet of the spreadsheet with the lookup columns,
+// the name cell of an unmatched key is marked like "未匹配: 2200009", and the sheet is saved to output.
+func fillLookupColumns(students []Student, excelFile, column, output string) ([]string, error) {
+	file, err := excelize.OpenFile(excelFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	sheet := file.GetSheetName(0)
+	rows, err := file.GetRows(sheet)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s is empty", excelFile)
+	}
+	col := util.FindColumn(rows[0], column)
+	if col == -1 {
+		return nil, fmt.Errorf("column %s is not found in %s", column, excelFile)
+	}
+	nextColName, err := excelize.ColumnNumberToName(col + 2)
+	if err != nil {
+		return nil, err
+	}
+	// the key column becomes the name column, followed by the other lookup columns
+	if err := file.InsertCols(sheet, nextColName, len(lookupHeaders)-1); err != nil {
+		return nil, err
+	}
+	var unmatched []string
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(col+1, i+1)
+		values := lookupHeaders
+		if i > 0 {
+			key := ""
+			if col < len(row) {
+				key = strings.TrimSpace(row[col])
+			}
+			if key == "" {
+				continue
+			}
+			if student, ok := lookupStudent(students, key); ok {
+				values = []string{student.Name, student.No, student.Class, student.Grade}
+			} else {
+				unmatched = append(unmatched, key)
+				values = []string{unmatchedPrefix + key, "", "", ""}
+			}
+		}
+		if err := file.SetSheetRow(sheet, cell, &values); err != nil {
+			return nil, err
+		}
+	}
+	return unmatched, file.SaveAs(output)
+}
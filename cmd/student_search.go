@@ -0,0 +1,151 @@
+/*
+Copyright © 2023 Lyu Lin <lvlin@whu.edu.cn>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackeylu/mytools/util"
+)
+
+// the scores of the ways a key matches a student, the higher the better
+const (
+	exactScore          = 100
+	pinyinScore         = 90
+	pinyinInitialsScore = 85
+	snoPrefixScore      = 80
+	partialNameScore    = 70
+	snoSubstringScore   = 60
+	pinyinPrefixScore   = 50
+	typoScore           = 40
+	pinyinTypoScore     = 30
+	// maxCandidates is the number of the candidates listed for picking
+	maxCandidates = 10
+)
+
+// studentMatch is a student matched by a key, with the score and the way of matching
+type studentMatch struct {
+	Student
+	Score  int
+	Reason string
+}
+
+// searchStudents returns the students matching the key, ranked by the score then the sno
+func searchStudents(students []Student, key string) []studentMatch {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil
+	}
+	var matches []studentMatch
+	for _, s := range students {
+		if score, reason := matchScore(s, key); score > 0 {
+			matches = append(matches, studentMatch{Student: s, Score: score, Reason: reason})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].No < matches[j].No
+	})
+	return matches
+}
+
+// matchScore scores the best way the key matches the student, a key of digits matches the sno,
+// a key of latin letters matches the pinyin of the name, and others match the name.
+func matchScore(s Student, key string) (int, string) {
+	if s.No == key || s.Name == key {
+		return exactScore, "exact"
+	}
+	switch {
+	case util.IsAllCharacterDigit(key):
+		switch {
+		case strings.HasPrefix(s.No, key):
+			return snoPrefixScore, "sno prefix"
+		case strings.Contains(s.No, key):
+			return snoSubstringScore, "sno substring"
+		case len(key) >= 6 && util.EditDistance(s.No, key) <= 1:
+			return typoScore, "sno typo"
+		}
+	case isLatin(key):
+		// the pinyin has no space, e.g. "zhang san" is "zhangsan"
+		key = strings.ToLower(strings.ReplaceAll(key, " ", ""))
+		full, initials := util.Pinyin(s.Name)
+		switch {
+		case full == key:
+			return pinyinScore, "pinyin"
+		case initials == key:
+			return pinyinInitialsScore, "pinyin initials"
+		case len(key) >= 2 && strings.HasPrefix(full, key):
+			return pinyinPrefixScore, "pinyin prefix"
+		case len(key) >= 4 && util.EditDistance(full, key) <= 1:
+			return pinyinTypoScore, "pinyin typo"
+		}
+	default:
+		switch {
+		case strings.Contains(s.Name, key):
+			return partialNameScore, "partial name"
+		case utf8.RuneCountInString(key) >= 2 && utf8.RuneCountInString(s.Name) >= 2 && util.EditDistance(s.Name, key) <= 1:
+			return typoScore, "name typo"
+		}
+	}
+	return 0, ""
+}
+
+func isLatin(key string) bool {
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == ' ') {
+			return false
+		}
+	}
+	return true
+}
+
+// pickStudent returns the student to copy, the only match or the only exact match is picked directly,
+// otherwise the candidates are listed to pick one. It returns false if nothing is picked.
+func pickStudent(key string, matches []studentMatch) (Student, bool, error) {
+	if len(matches) == 0 {
+		return Student{}, false, nil
+	}
+	if len(matches) == 1 || matches[0].Score == exactScore && matches[1].Score < exactScore {
+		return matches[0].Student, true, nil
+	}
+	if len(matches) > maxCandidates {
+		matches = matches[:maxCandidates]
+	}
+	fmt.Printf("%d students match %s:\n", len(matches), key)
+	choices := []string{""}
+	for i, m := range matches {
+		fmt.Printf("%2d. %v (%s)\n", i+1, m.Student, m.Reason)
+		choices = append(choices, strconv.Itoa(i+1))
+	}
+	choice, err := util.CheckInput(fmt.Sprintf("请选择学生序号 (1-%d)，直接回车跳过: ", len(matches)), choices...)
+	if err != nil || choice == "" {
+		return Student{}, false, err
+	}
+	i, _ := strconv.Atoi(choice)
+	return matches[i-1].Student, true, nil
+}
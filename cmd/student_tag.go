@@ -0,0 +1,129 @@
+/*
+Copyright © 2023 Lyu Lin <lvlin@whu.edu.cn>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/atotto/clipboard"
+	"github.com/spf13/viper"
+)
+
+const (
+	tagTargetClipboard = "clipboard"
+	tagTargetOSC52     = "osc52"
+	tagTargetStdout    = "stdout"
+)
+
+var (
+	// the text/template of the tag, default is student.tag
+	tagFormat string
+	// the targets of the tag tried in order, default is student.targets
+	tagTargets []string
+)
+
+// defaultTagTargets tries the system clipboard, then the terminal clipboard over OSC 52, then stdout
+var defaultTagTargets = []string{tagTargetClipboard, tagTargetOSC52, tagTargetStdout}
+
+// errNotTerminal tells the OSC 52 escape is not written since stdout is not a terminal
+var errNotTerminal = errors.New("stdout is not a terminal")
+
+// tagTemplate parses the tag format given by --tag or student.tag, such as "{{.No}}_{{.Name}}_{{.Class}}",
+// the default is "{{.Name}}-{{.No}}", or "{{.No}}-{{.Name}}" with --reverse.
+func tagTemplate() (*template.Template, error) {
+	format := tagFormat
+	if format == "" {
+		format = viper.GetString("student.tag")
+	}
+	if format == "" {
+		format = "{{.Name}}-{{.No}}"
+		if reverse {
+			format = "{{.No}}-{{.Name}}"
+		}
+	}
+	tmpl, err := template.New("tag").Option("missingkey=error").Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("illegal tag format %q: %w", format, err)
+	}
+	return tmpl, nil
+}
+
+func studentTag(tmpl *template.Template, student Student) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, student); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// writeTag writes the tag to the first target that works, and returns the target
+func writeTag(tag string, targets []string) (string, error) {
+	var errs []error
+	for _, target := range targets {
+		var err error
+		switch target {
+		case tagTargetClipboard:
+			err = clipboard.WriteAll(tag)
+		case tagTargetOSC52:
+			err = writeOSC52(os.Stdout, tag)
+		case tagTargetStdout:
+			_, err = fmt.Println(tag)
+		default:
+			err = fmt.Errorf("unknown target, expecting %s", strings.Join(defaultTagTargets, ", "))
+		}
+		if err == nil {
+			return target, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", target, err))
+	}
+	return "", errors.Join(errs...)
+}
+
+// writeOSC52 asks the terminal to copy the tag into the clipboard of the local machine,
+// which works over SSH with the terminals supporting OSC 52.
+func writeOSC52(f *os.File, tag string) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeCharDevice == 0 {
+		return errNotTerminal
+	}
+	_, err = io.WriteString(f, osc52Sequence(tag, os.Getenv("TMUX") != ""))
+	return err
+}
+
+// osc52Sequence builds the OSC 52 escape, tmux passes it through only when wrapped in a DCS sequence
+func osc52Sequence(tag string, tmux bool) string {
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(tag)) + "\a"
+	if tmux {
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+	return seq
+}
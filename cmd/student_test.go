@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jackeylu/mytools/util"
+)
+
+var allStudents = []Student{
+	{Name: "张三", No: "2200001", Class: "信安一班", Grade: "2022"},
+	{Name: "张山", No: "2200002", Class: "信安一班", Grade: "2022"},
+	{Name: "李四", No: "2200013", Class: "信安二班", Grade: "2022"},
+	{Name: "赵六", No: "2100001", Class: "信安一班", Grade: "2021"},
+}
+
+func TestSearchStudents(t *testing.T) {
+	cases := []struct {
+		key      string
+		expected []string
+	}{
+		{"2200001", []string{"2200001 exact", "2100001 sno typo", "2200002 sno typo"}},
+		{"22000", []string{"2200001 sno prefix", "2200002 sno prefix", "2200013 sno prefix"}},
+		{"0013", []string{"2200013 sno substring"}},
+		{"张", []string{"2200001 partial name", "2200002 partial name"}},
+		{"zs", []string{"2200001 pinyin initials", "2200002 pinyin initials"}},
+		{"LiSi", []string{"2200013 pinyin"}},
+		{"zhang san", []string{"2200001 pinyin", "2200002 pinyin typo"}},
+		{"zhaoliu", []string{"2100001 pinyin"}},
+		{"zhaoliv", []string{"2100001 pinyin typo"}},
+		{"李思", []string{"2200013 name typo"}},
+		{"王五", nil},
+	}
+	for _, c := range cases {
+		var got []string
+		for _, m := range searchStudents(allStudents, c.key) {
+			got = append(got, m.No+" "+m.Reason)
+		}
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("%s: expected %v, but got %v", c.key, c.expected, got)
+		}
+	}
+}
+
+func TestPickStudent(t *testing.T) {
+	matches := searchStudents(allStudents, "2200001")
+	student, ok, err := pickStudent("2200001", matches)
+	if err != nil || !ok || student.Name != "张三" {
+		t.Errorf("Expected the exact match 张三, but got %v %v %v", student, ok, err)
+	}
+	if _, ok, _ := pickStudent("王五", nil); ok {
+		t.Error("Expected nothing picked")
+	}
+}
+
+func TestLookupStudents(t *testing.T) {
+	keys, err := readKeys(strings.NewReader("2200001\n\n 李四 \n张\n王五\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range lookupStudents(allStudents, keys) {
+		got = append(got, fmt.Sprintf("%s:%s:%v", r.Key, r.Student.No, r.Found))
+	}
+	// a partial name is not taken in batch lookup
+	expected := []string{"2200001:2200001:true", "李四:2200013:true", "张::false", "王五::false"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, but got %v", expected, got)
+	}
+}
+
+func TestFillLookupColumns(t *testing.T) {
+	excelFile := filepath.Join(t.TempDir(), "scores.xlsx")
+	if err := util.WriteExcelFile(excelFile, []string{"序号", "学号", "成绩"},
+		[][]string{{"1", "2200013", "90"}, {"2", "2200009", "80"}}); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(t.TempDir(), "filled.xlsx")
+	unmatched, err := fillLookupColumns(allStudents, excelFile, "学号", output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(unmatched, []string{"2200009"}) {
+		t.Errorf("Expected 2200009 unmatched, but got %v", unmatched)
+	}
+	var rows [][]string
+	if err := util.ReadExcelFile(output, func(_ int, row []string) error {
+		rows = append(rows, row)
+		return nil
+	}, false); err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{
+		{"序号", "姓名", "学号", "班级", "年级", "成绩"},
+		{"1", "李四", "2200013", "信安二班", "2022", "90"},
+		{"2", "未匹配: 2200009", "", "", "", "80"},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Expected %v, but got %v", expected, rows)
+	}
+	if got := filledFileName("dir/scores.xlsx"); got != "dir/scores-filled.xlsx" {
+		t.Errorf("Expected the output next to the spreadsheet, but got %s", got)
+	}
+}
+
+func TestStudentTag(t *testing.T) {
+	tmpl, err := tagTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag, _ := studentTag(tmpl, allStudents[0]); tag != "张三-2200001" {
+		t.Errorf("Expected the default tag 张三-2200001, but got %s", tag)
+	}
+
+	tagFormat = "{{.No}}_{{.Name}}_{{.Class}}"
+	defer func() { tagFormat = "" }()
+	if tmpl, err = tagTemplate(); err != nil {
+		t.Fatal(err)
+	}
+	if tag, _ := studentTag(tmpl, allStudents[0]); tag != "2200001_张三_信安一班" {
+		t.Errorf("Expected 2200001_张三_信安一班, but got %s", tag)
+	}
+	tagFormat = "{{.Age}}"
+	if tmpl, err = tagTemplate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := studentTag(tmpl, allStudents[0]); err == nil {
+		t.Error("Expected an error of the unknown field")
+	}
+}
+
+func TestWriteTag(t *testing.T) {
+	if seq := osc52Sequence("张三", false); seq != "\x1b]52;c;5byg5LiJ\a" {
+		t.Errorf("Unexpected OSC 52 sequence %q", seq)
+	}
+	if seq := osc52Sequence("张三", true); seq != "\x1bPtmux;\x1b\x1b]52;c;5byg5LiJ\a\x1b\\" {
+		t.Errorf("Unexpected tmux sequence %q", seq)
+	}
+	// stdout of the test is not a terminal, so it falls back to stdout
+	target, err := writeTag("张三-2200001", []string{tagTargetOSC52, tagTargetStdout})
+	if err != nil || target != tagTargetStdout {
+		t.Errorf("Expected the fallback to stdout, but got %s %v", target, err)
+	}
+	if _, err := writeTag("张三-2200001", []string{"printer"}); err == nil {
+		t.Error("Expected an error of the unknown target")
+	}
+}
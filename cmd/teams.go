@@ -0,0 +1,437 @@
+/*
+Copyright © 2023 Lyu Lin <lvlin@whu.edu.cn>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackeylu/mytools/util"
+	"github.com/spf13/cobra"
+)
+
+var (
+	teamsClass    string
+	teamsSize     int
+	teamsScores   string
+	teamsColumn   string
+	teamsApart    []string
+	teamsTogether []string
+	teamsSeed     int64
+	teamsOutput   string
+	teamsText     string
+)
+
+// maxTeamAttempts is the number of the shuffles tried to satisfy the constraints
+const maxTeamAttempts = 100
+
+// team is a group of students
+type team struct {
+	Members []CourseStudent
+	// Total is the sum of the balancing scores of the members
+	Total float64
+}
+
+// Mean returns the mean of the balancing scores of the members
+func (t team) Mean() float64 {
+	if len(t.Members) == 0 {
+		return 0
+	}
+	return t.Total / float64(len(t.Members))
+}
+
+// teamsCmd represents the teams command
+var teamsCmd = &cobra.Command{
+	Use:   "teams",
+	Short: "将班级随机分组，可按成绩均衡并指定必须同组或不能同组的学生.",
+	Long: `Split the students of a class into the teams of the target size at random, the sizes of the
+teams differ by one at most. The class is a namelist configured by 'lab.class.<class>', a namelist
+file, or a class of the roster registry.
+
+With --scores, the teams are balanced by a numeric column of the xlsx file matched by '学号', e.g. the
+grades of the previous course, and the students without a score are taken as the average.
+The students of a --together group are in the same team, and the students of an --apart group are
+in different teams, the students are given by the names or the snos separated by commas.
+
+The same --seed forms the same teams, the seed is printed to reproduce a random one.
+
+Example:
+
+$ mytools teams -c php-2023-class-1 -s 4 --scores grades.xlsx --column 总评 --apart 张三,李四 --together 王五,赵六`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		students, err := loadPickRoster(teamsClass)
+		if err != nil {
+			return err
+		}
+		if teamsSize < 2 {
+			return fmt.Errorf("the team size should be 2 at least, but got %d", teamsSize)
+		}
+		var scores map[string]float64
+		if teamsScores != "" {
+			if scores, err = readTeamScores(teamsScores, teamsColumn); err != nil {
+				return err
+			}
+		}
+		together, err := parseTeamConstraints(students, teamsTogether)
+		if err != nil {
+			return err
+		}
+		apart, err := parseTeamConstraints(students, teamsApart)
+		if err != nil {
+			return err
+		}
+		if teamsSeed == 0 {
+			teamsSeed = time.Now().UnixNano()
+		}
+		teams, err := formTeams(rand.New(rand.NewSource(teamsSeed)), students, teamsSize, scores, together, apart)
+		if err != nil {
+			return err
+		}
+		text := formatTeams(teams, scores != nil)
+		fmt.Print(text)
+		fmt.Printf("Seed: %d, use --seed %d to form the same teams.\n", teamsSeed, teamsSeed)
+		if teamsText != "" {
+			if err := os.WriteFile(teamsText, []byte(text), 0644); err != nil {
+				return err
+			}
+			fmt.Println("The teams are saved in", teamsText)
+		}
+		if teamsOutput == "" {
+			teamsOutput = strings.TrimSuffix(filepath.Base(teamsClass), filepath.Ext(teamsClass)) + "-teams.xlsx"
+		}
+		if err := util.WriteExcelSheets(teamsOutput, teamSheets(teams, scores != nil)); err != nil {
+			return err
+		}
+		fmt.Println("The teams are saved in", teamsOutput)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(teamsCmd)
+
+	teamsCmd.Flags().StringVarP(&teamsClass, "class", "c", "", "the class, a namelist file or a class of the registry")
+	teamsCmd.Flags().IntVarP(&teamsSize, "size", "s", 4, "the target size of the teams, 2 at least")
+	teamsCmd.Flags().StringVar(&teamsScores, "scores", "", "the xlsx file with the scores to balance the teams")
+	teamsCmd.Flags().StringVar(&teamsColumn, "column", "成绩", "the header of the score column of --scores")
+	teamsCmd.Flags().StringArrayVar(&teamsApart, "apart", nil, "the students in different teams, e.g. 张三,李四")
+	teamsCmd.Flags().StringArrayVar(&teamsTogether, "together", nil, "the students in the same team, e.g. 2200001,2200002")
+	teamsCmd.Flags().Int64Var(&teamsSeed, "seed", 0, "the seed of the random teams, default is a random one")
+	teamsCmd.Flags().StringVarP(&teamsOutput, "output", "o", "", "the xlsx file, default is <class>-teams.xlsx")
+	teamsCmd.Flags().StringVar(&teamsText, "text", "", "also save the printable list into the text file")
+}
+
+// readTeamScores reads the scores of the column keyed by the sno
+func readTeamScores(excelFile string, column string) (map[string]float64, error) {
+	scores := make(map[string]float64)
+	snoCol, scoreCol := -1, -1
+	err := util.ReadExcelFile(excelFile, func(i int, line []string) error {
+		if i == 0 {
+			snoCol = util.FindColumn(line, "学号", "sno", "no")
+			scoreCol = util.FindColumn(line, column)
+			if snoCol == -1 || scoreCol == -1 {
+				return fmt.Errorf("the header should have the columns '学号' and '%s', but got %v", column, line)
+			}
+			return nil
+		}
+		if snoCol >= len(line) || scoreCol >= len(line) || strings.TrimSpace(line[scoreCol]) == "" {
+			return nil
+		}
+		score, err := strconv.ParseFloat(strings.TrimSpace(line[scoreCol]), 64)
+		if err != nil {
+			return fmt.Errorf("row %d: illegal score '%s'", i+1, line[scoreCol])
+		}
+		scores[strings.TrimSpace(line[snoCol])] = score
+		return nil
+	}, false)
+	if err != nil {
+		return nil, err
+	}
+	return scores, nil
+}
+
+// parseTeamConstraints finds the students of every group given by the names or the snos separated by commas
+func parseTeamConstraints(students []CourseStudent, groups []string) ([][]int, error) {
+	var constraints [][]int
+	for _, group := range groups {
+		var members []int
+		for _, key := range strings.FieldsFunc(group, func(r rune) bool { return r == ',' || r == '，' }) {
+			key = strings.TrimSpace(key)
+			i := findUnique(students, func(s CourseStudent) bool { return s.Sno == key })
+			if i == -1 {
+				i = findUnique(students, func(s CourseStudent) bool { return s.Name == key })
+			}
+			if i == -1 {
+				return nil, fmt.Errorf("student %s of %s is not found or not unique", key, group)
+			}
+			members = append(members, i)
+		}
+		if len(members) < 2 {
+			return nil, fmt.Errorf("the group %s should have 2 students at least", group)
+		}
+		constraints = append(constraints, members)
+	}
+	return constraints, nil
+}
+
+// teamCapacities returns the sizes of the teams differing by one at most, the number of the teams is chosen
+// to make the mean deviation of the sizes from the target the least, fewer teams on a tie.
+// The teams of a single student are avoided.
+func teamCapacities(n, size int) []int {
+	abs := func(d int) int {
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+	// deviation is the total deviation of the k teams from the target
+	deviation := func(k int) int {
+		q, r := n/k, n%k
+		return r*abs(q+1-size) + (k-r)*abs(q-size)
+	}
+	k := 1
+	// a student is not left alone in a team
+	for i := 2; n/i >= 2; i++ {
+		// deviation(i)/i < deviation(k)/k
+		if deviation(i)*k < deviation(k)*i {
+			k = i
+		}
+	}
+	capacities := make([]int, k)
+	for i := range capacities {
+		capacities[i] = n / k
+		if i < n%k {
+			capacities[i]++
+		}
+	}
+	return capacities
+}
+
+// formTeams splits the students into the teams, the students of a together group are kept in a unit
+// assigned as a whole. With the scores, the units are assigned from the highest score to the team
+// farthest below the average, otherwise to the team with the most room. The units are shuffled again
+// when the apart groups can not be satisfied.
+func formTeams(r *rand.Rand, students []CourseStudent, size int, scores map[string]float64,
+	together, apart [][]int) ([]team, error) {
+	if len(students) == 0 {
+		return nil, fmt.Errorf("no student to form the teams")
+	}
+	capacities := teamCapacities(len(students), size)
+
+	// the students of the overlapped together groups are in the same unit
+	unitOf := make([]int, len(students))
+	for i := range unitOf {
+		unitOf[i] = i
+	}
+	var root func(i int) int
+	root = func(i int) int {
+		if unitOf[i] != i {
+			unitOf[i] = root(unitOf[i])
+		}
+		return unitOf[i]
+	}
+	for _, group := range together {
+		for _, i := range group[1:] {
+			unitOf[root(i)] = root(group[0])
+		}
+	}
+	members := make(map[int][]int)
+	for i := range students {
+		members[root(i)] = append(members[root(i)], i)
+	}
+	var units [][]int
+	for i := range students {
+		if root(i) == i {
+			units = append(units, members[i])
+		}
+	}
+
+	conflicts := make(map[[2]int]bool)
+	for _, group := range apart {
+		for _, i := range group {
+			for _, j := range group {
+				if i != j {
+					if root(i) == root(j) {
+						return nil, fmt.Errorf("%s and %s should be both together and apart", students[i].Name, students[j].Name)
+					}
+					conflicts[[2]int{i, j}] = true
+				}
+			}
+		}
+	}
+	for _, unit := range units {
+		if len(unit) > capacities[0] {
+			return nil, fmt.Errorf("%d students are kept together, more than the team size %d", len(unit), capacities[0])
+		}
+	}
+
+	// the students without a score are taken as the average
+	score := func(i int) float64 { return 0 }
+	average := 0.0
+	if scores != nil {
+		var sum float64
+		var count int
+		for _, s := range students {
+			if v, ok := scores[s.Sno]; ok {
+				sum += v
+				count++
+			}
+		}
+		if count > 0 {
+			average = sum / float64(count)
+		}
+		score = func(i int) float64 {
+			if v, ok := scores[students[i].Sno]; ok {
+				return v
+			}
+			return average
+		}
+	}
+	// deviation is how much the total of a team is above the average of its members
+	deviation := func(t int, totals []float64, teams [][]int) float64 {
+		return totals[t] - average*float64(len(teams[t]))
+	}
+	unitScore := func(unit []int) float64 {
+		var sum float64
+		for _, i := range unit {
+			sum += score(i)
+		}
+		return sum
+	}
+
+	for attempt := 0; attempt < maxTeamAttempts; attempt++ {
+		r.Shuffle(len(units), func(i, j int) { units[i], units[j] = units[j], units[i] })
+		// the larger and the apart units first to fit in the teams, and then the higher scores to balance,
+		// the scores are not sorted in the later attempts to try other orders
+		sort.SliceStable(units, func(i, j int) bool {
+			if len(units[i]) != len(units[j]) {
+				return len(units[i]) > len(units[j])
+			}
+			if ci, cj := isApart(conflicts, units[i]), isApart(conflicts, units[j]); ci != cj {
+				return ci
+			}
+			return scores != nil && attempt == 0 &&
+				unitScore(units[i])/float64(len(units[i])) > unitScore(units[j])/float64(len(units[j]))
+		})
+		teams := make([][]int, len(capacities))
+		totals := make([]float64, len(capacities))
+		ok := true
+		for _, unit := range units {
+			best := -1
+			for t := range teams {
+				if capacities[t]-len(teams[t]) < len(unit) || conflicted(conflicts, teams[t], unit) {
+					continue
+				}
+				if best == -1 {
+					best = t
+				} else if scores != nil && deviation(t, totals, teams) < deviation(best, totals, teams) {
+					best = t
+				} else if scores == nil && capacities[t]-len(teams[t]) > capacities[best]-len(teams[best]) {
+					best = t
+				}
+			}
+			if best == -1 {
+				ok = false
+				break
+			}
+			teams[best] = append(teams[best], unit...)
+			totals[best] += unitScore(unit)
+		}
+		if !ok {
+			continue
+		}
+		result := make([]team, len(teams))
+		for t, indexes := range teams {
+			sort.Ints(indexes)
+			for _, i := range indexes {
+				result[t].Members = append(result[t].Members, students[i])
+			}
+			result[t].Total = totals[t]
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("can not form %d teams of %d students with the constraints", len(capacities), len(students))
+}
+
+// isApart tells whether any student of the unit should be apart from the others
+func isApart(conflicts map[[2]int]bool, unit []int) bool {
+	for _, i := range unit {
+		for pair := range conflicts {
+			if pair[0] == i {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// conflicted tells whether any student of the unit should be apart from the members
+func conflicted(conflicts map[[2]int]bool, members []int, unit []int) bool {
+	for _, i := range members {
+		for _, j := range unit {
+			if conflicts[[2]int{i, j}] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// formatTeams formats the printable list of the teams
+func formatTeams(teams []team, scored bool) string {
+	var b strings.Builder
+	for t, team := range teams {
+		if scored {
+			fmt.Fprintf(&b, "第%d组 (%d人, 平均 %s)\n", t+1, len(team.Members), formatGrade(roundHalfUp(team.Mean(), 1)))
+		} else {
+			fmt.Fprintf(&b, "第%d组 (%d人)\n", t+1, len(team.Members))
+		}
+		for _, s := range team.Members {
+			fmt.Fprintf(&b, "  %s %s\n", s.Sno, s.Name)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// teamSheets builds the sheet of the members, and the sheet of the averages if scored
+func teamSheets(teams []team, scored bool) []util.Sheet {
+	members := util.Sheet{Name: "分组", Headers: []string{"组号", "学号", "姓名", "班级"}, NumericColumns: []int{0}}
+	summary := util.Sheet{Name: "组统计", Headers: []string{"组号", "人数", "平均分"}, NumericColumns: []int{0, 1, 2}}
+	for t, team := range teams {
+		for _, s := range team.Members {
+			members.Rows = append(members.Rows, []string{strconv.Itoa(t + 1), s.Sno, s.Name, s.Class})
+		}
+		summary.Rows = append(summary.Rows, []string{strconv.Itoa(t + 1), strconv.Itoa(len(team.Members)),
+			formatGrade(roundHalfUp(team.Mean(), 2))})
+	}
+	if !scored {
+		return []util.Sheet{members}
+	}
+	return []util.Sheet{members, summary}
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestTeamCapacities(t *testing.T) {
+	for _, tc := range []struct {
+		n, size  int
+		expected []int
+	}{
+		{10, 4, []int{4, 3, 3}},
+		// 4+3+3 is off by 1/3 on average, 3+3+2+2 by 1/2
+		{10, 3, []int{4, 3, 3}},
+		{12, 4, []int{4, 4, 4}},
+		{2, 4, []int{2}},
+		// 4+3 instead of one team of 7
+		{7, 5, []int{4, 3}},
+		{9, 4, []int{5, 4}},
+		// 3+2+2+2 instead of four teams of 2 and a single student
+		{9, 2, []int{3, 2, 2, 2}},
+		{5, 4, []int{5}},
+		{30, 8, []int{8, 8, 7, 7}},
+	} {
+		if got := teamCapacities(tc.n, tc.size); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("teamCapacities(%d, %d) = %v, want %v", tc.n, tc.size, got, tc.expected)
+		}
+	}
+}
+
+func TestFormTeams(t *testing.T) {
+	var students []CourseStudent
+	scores := make(map[string]float64)
+	for i := 0; i < 12; i++ {
+		sno := fmt.Sprintf("22000%02d", i+1)
+		students = append(students, CourseStudent{Name: fmt.Sprintf("学生%d", i+1), Sno: sno})
+		scores[sno] = float64(50 + 4*i)
+	}
+	together, err := parseTeamConstraints(students, []string{"学生1,2200002"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	apart, err := parseTeamConstraints(students, []string{"学生3,学生4，学生5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	teams, err := formTeams(rand.New(rand.NewSource(1)), students, 4, scores, together, apart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	teamOf := make(map[string]int)
+	for i, team := range teams {
+		if len(team.Members) != 4 {
+			t.Errorf("Expected 4 members of every team, but got %v", team.Members)
+		}
+		if team.Mean() < 65 || team.Mean() > 79 {
+			t.Errorf("Expected the balanced teams, but got the mean %v of %v", team.Mean(), team.Members)
+		}
+		for _, s := range team.Members {
+			teamOf[s.Name] = i
+		}
+	}
+	if teamOf["学生1"] != teamOf["学生2"] {
+		t.Errorf("Expected 学生1 and 学生2 together, but got %v", teams)
+	}
+	if teamOf["学生3"] == teamOf["学生4"] || teamOf["学生3"] == teamOf["学生5"] || teamOf["学生4"] == teamOf["学生5"] {
+		t.Errorf("Expected 学生3, 学生4 and 学生5 apart, but got %v", teams)
+	}
+
+	// the same seed forms the same teams
+	again, _ := formTeams(rand.New(rand.NewSource(1)), students, 4, scores, together, apart)
+	if !reflect.DeepEqual(teams, again) {
+		t.Errorf("Expected the same teams of the same seed, but got %v and %v", teams, again)
+	}
+
+	if _, err := formTeams(rand.New(rand.NewSource(1)), students, 4, nil, together, [][]int{{0, 1}}); err == nil {
+		t.Error("Expected an error of the students both together and apart")
+	}
+	if _, err := parseTeamConstraints(students, []string{"学生1,王五"}); err == nil {
+		t.Error("Expected an error of the unknown student")
+	}
+}
@@ -0,0 +1,82 @@
+package util
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ReadDocxParagraphs 读取docx文件正文（word/document.xml）中的所有段落文本
+func ReadDocxParagraphs(docxFile string) ([]string, error) {
+	r, err := zip.OpenReader(docxFile)
+	if err != nil {
+		return nil, fmt.Errorf("error opening docx file %s: %w", docxFile, err)
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		if f.Name != "word/document.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return parseDocumentXML(rc)
+	}
+	return nil, fmt.Errorf("word/document.xml not found in %s", docxFile)
+}
+
+// ReadDocxText 读取docx文件正文的纯文本，段落之间以换行分隔
+func ReadDocxText(docxFile string) (string, error) {
+	paragraphs, err := ReadDocxParagraphs(docxFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(paragraphs, "\n"), nil
+}
+
+func parseDocumentXML(r io.Reader) ([]string, error) {
+	var paragraphs []string
+	var paragraph strings.Builder
+	inText := false
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				paragraph.WriteString("\t")
+			case "br", "cr":
+				paragraph.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				paragraphs = append(paragraphs, paragraph.String())
+				paragraph.Reset()
+			}
+		case xml.CharData:
+			if inText {
+				paragraph.Write(t)
+			}
+		}
+	}
+	if paragraph.Len() > 0 {
+		paragraphs = append(paragraphs, paragraph.String())
+	}
+	return paragraphs, nil
+}
@@ -0,0 +1,52 @@
+package util
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeDocx writes a minimal docx file with the given word/document.xml body
+func writeDocx(t *testing.T, docxFile, body string) {
+	t.Helper()
+	f, err := os.Create(docxFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	writer, err := w.Create("word/document.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Write([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+		body + `</w:body></w:document>`))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadDocxParagraphs(t *testing.T) {
+	docxFile := filepath.Join(t.TempDir(), "report.docx")
+	writeDocx(t, docxFile,
+		`<w:p><w:r><w:t>实验目的</w:t></w:r></w:p>`+
+			`<w:p><w:r><w:t xml:space="preserve">掌握 </w:t></w:r><w:r><w:t>PHP</w:t><w:tab/><w:t>语法</w:t></w:r></w:p>`)
+
+	paragraphs, err := ReadDocxParagraphs(docxFile)
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if expected := []string{"实验目的", "掌握 PHP\t语法"}; !reflect.DeepEqual(paragraphs, expected) {
+		t.Errorf("Expected %q, but got %q", expected, paragraphs)
+	}
+	if text, _ := ReadDocxText(docxFile); text != "实验目的\n掌握 PHP\t语法" {
+		t.Errorf("unexpected text %q", text)
+	}
+
+	if _, err := ReadDocxText(filepath.Join(t.TempDir(), "missing.docx")); err == nil {
+		t.Error("Expected error on missing file, but got nil")
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/xuri/excelize/v2"
 )
@@ -16,9 +18,14 @@ func fileExists(filename string) bool {
 	return !info.IsDir()
 }
 
+// ErrFileNotFound 表示要读取的文件不存在
+var ErrFileNotFound = errors.New("file not found")
+
+// ReadExcelFile 逐行读取excel文件的第一张表，文件不存在时返回ErrFileNotFound，
+// f返回的错误会被包装后返回，可以用errors.Is或errors.As判断
 func ReadExcelFile(excelFile string, f func(int, []string) error, ignoreHeader bool) error {
 	if !fileExists(excelFile) {
-		panic(fmt.Sprintf("file [%s] not found", excelFile))
+		return fmt.Errorf("%w: [%s]", ErrFileNotFound, excelFile)
 	}
 	file, err := excelize.OpenFile(excelFile)
 	if err != nil {
@@ -32,12 +39,12 @@ func ReadExcelFile(excelFile string, f func(int, []string) error, ignoreHeader b
 	if err != nil {
 		return fmt.Errorf("error getting rows from first sheet:%v", err)
 	}
-	if ignoreHeader {
+	if ignoreHeader && len(rows) > 0 {
 		rows = rows[1:]
 	}
 	for i, row := range rows {
 		if err = f(i, row); err != nil {
-			return fmt.Errorf("error on handle %d row [%v] with %v", i, row, err)
+			return fmt.Errorf("error on handle %d row [%v] with %w", i, row, err)
 		}
 	}
 	return nil
@@ -100,3 +107,73 @@ func WriteOrAppendExcelFile(excelFile string, headers []string, rows [][]string,
 	file.SaveAs(excelFile)
 	return nil
 }
+
+// Sheet 是一个待写入的工作表，包括表名、表头和数据行
+type Sheet struct {
+	Name    string
+	Headers []string
+	Rows    [][]string
+	// NumericColumns 中的列（从0开始）如果可以解析为数字，则按数字写入单元格
+	NumericColumns []int
+}
+
+// cells 将一行数据转换为单元格的值
+func (s Sheet) cells(row []string) []interface{} {
+	cells := make([]interface{}, len(row))
+	for i, v := range row {
+		cells[i] = v
+	}
+	for _, col := range s.NumericColumns {
+		if col < len(row) {
+			if f, err := strconv.ParseFloat(row[col], 64); err == nil {
+				cells[col] = f
+			}
+		}
+	}
+	return cells
+}
+
+// WriteExcelSheets 覆盖写多个工作表到excel文件中，第一个工作表替换默认的Sheet1
+func WriteExcelSheets(excelFile string, sheets []Sheet) error {
+	if len(sheets) == 0 {
+		return errors.New("no sheet to write")
+	}
+	file := excelize.NewFile()
+	defer file.Close()
+
+	for i, sheet := range sheets {
+		if i == 0 {
+			if err := file.SetSheetName("Sheet1", sheet.Name); err != nil {
+				return err
+			}
+		} else if _, err := file.NewSheet(sheet.Name); err != nil {
+			return err
+		}
+		if err := file.SetSheetRow(sheet.Name, "A1", &sheet.Headers); err != nil {
+			return err
+		}
+		for idx, row := range sheet.Rows {
+			cell, err := excelize.CoordinatesToCellName(1, idx+2)
+			if err != nil {
+				return err
+			}
+			cells := sheet.cells(row)
+			if err := file.SetSheetRow(sheet.Name, cell, &cells); err != nil {
+				return err
+			}
+		}
+	}
+	return file.SaveAs(excelFile)
+}
+
+// FindColumn 在表头中查找第一个与候选列名相同的列（忽略大小写和首尾空格），未找到时返回-1
+func FindColumn(headers []string, names ...string) int {
+	for i, header := range headers {
+		for _, name := range names {
+			if strings.EqualFold(strings.TrimSpace(header), name) {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -1,8 +1,10 @@
 package util
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/xuri/excelize/v2"
@@ -140,3 +142,69 @@ func TestExcelExample(t *testing.T) {
 		fmt.Println(err)
 	}
 }
+
+func TestWriteExcelSheets(t *testing.T) {
+	excelFile := "test_sheets.xlsx"
+	defer os.Remove(excelFile)
+
+	err := WriteExcelSheets(excelFile, []Sheet{
+		{Name: "结果", Headers: []string{"Name", "Age"}, Rows: [][]string{{"John Doe", "25"}}},
+		{Name: "待复核", Headers: []string{"Name", "Age"}, Rows: [][]string{{"Jane Smith", "30"}}, NumericColumns: []int{1}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+
+	file, err := excelize.OpenFile(excelFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if got := file.GetSheetList(); !checkContent([][]string{got}, [][]string{{"结果", "待复核"}}) {
+		t.Errorf("Expected sheets [结果 待复核], but got %v", got)
+	}
+	rows, _ := file.GetRows("结果")
+	if !checkContent(rows, [][]string{{"Name", "Age"}, {"John Doe", "25"}}) {
+		t.Errorf("unexpected content of the first sheet: %v", rows)
+	}
+	rows, _ = file.GetRows("待复核")
+	if !checkContent(rows, [][]string{{"Name", "Age"}, {"Jane Smith", "30"}}) {
+		t.Errorf("unexpected content of the second sheet: %v", rows)
+	}
+	if cellType, _ := file.GetCellType("待复核", "B2"); cellType != excelize.CellTypeUnset {
+		t.Errorf("Expected a numeric cell, but got type %v", cellType)
+	}
+	if cellType, _ := file.GetCellType("结果", "B2"); cellType != excelize.CellTypeSharedString {
+		t.Errorf("Expected a string cell, but got type %v", cellType)
+	}
+}
+
+func TestFindColumn(t *testing.T) {
+	headers := []string{"序号", " 学号 ", "Score"}
+	if got := FindColumn(headers, "sno", "学号"); got != 1 {
+		t.Errorf("Expected 1, but got %d", got)
+	}
+	if got := FindColumn(headers, "score", "成绩"); got != 2 {
+		t.Errorf("Expected 2, but got %d", got)
+	}
+	if got := FindColumn(headers, "姓名"); got != -1 {
+		t.Errorf("Expected -1, but got %d", got)
+	}
+}
+
+func TestReadExcelFileErrors(t *testing.T) {
+	err := ReadExcelFile(filepath.Join(t.TempDir(), "missing.xlsx"), func(int, []string) error { return nil }, true)
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("Expected ErrFileNotFound, but got %v", err)
+	}
+
+	excelFile := filepath.Join(t.TempDir(), "test.xlsx")
+	if err := WriteExcelFile(excelFile, []string{"Name"}, [][]string{{"John Doe"}}); err != nil {
+		t.Fatal(err)
+	}
+	errStop := errors.New("stop")
+	err = ReadExcelFile(excelFile, func(int, []string) error { return errStop }, true)
+	if !errors.Is(err, errStop) {
+		t.Errorf("Expected the error of the callback, but got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package util
+
+import (
+	"strings"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+// Pinyin 返回汉字的全拼和首字母，均为小写不带声调，非汉字原样保留，如"张三"返回"zhangsan"和"zs"
+func Pinyin(text string) (full string, initials string) {
+	args := pinyin.NewArgs()
+	args.Fallback = func(r rune, a pinyin.Args) []string {
+		return []string{strings.ToLower(string(r))}
+	}
+	var fullBuilder, initialsBuilder strings.Builder
+	for _, syllable := range pinyin.Pinyin(text, args) {
+		if len(syllable) == 0 || syllable[0] == "" {
+			continue
+		}
+		fullBuilder.WriteString(syllable[0])
+		initialsBuilder.WriteString(syllable[0][:1])
+	}
+	return fullBuilder.String(), initialsBuilder.String()
+}
+
+// EditDistance 按字符计算两个字符串的编辑距离
+func EditDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	prev := make([]int, len(t)+1)
+	curr := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		curr[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(t)]
+}
@@ -0,0 +1,37 @@
+package util
+
+import "testing"
+
+func TestPinyin(t *testing.T) {
+	cases := []struct {
+		text, full, initials string
+	}{
+		{"张三", "zhangsan", "zs"},
+		{"欧阳Li", "ouyangli", "oyli"},
+		{"", "", ""},
+	}
+	for _, c := range cases {
+		full, initials := Pinyin(c.text)
+		if full != c.full || initials != c.initials {
+			t.Errorf("Pinyin(%q) = %q, %q, expected %q, %q", c.text, full, initials, c.full, c.initials)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		distance int
+	}{
+		{"张三", "张三", 0},
+		{"张三", "张山", 1},
+		{"2200001", "2200010", 2},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+	}
+	for _, c := range cases {
+		if d := EditDistance(c.a, c.b); d != c.distance {
+			t.Errorf("EditDistance(%q, %q) = %d, expected %d", c.a, c.b, d, c.distance)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package util
+
+import (
+	"hash/fnv"
+	"sort"
+	"unicode"
+)
+
+// Shingles 是一段文本按k个字符切分的所有片段的哈希值，空白字符被忽略
+type Shingles struct {
+	k      int
+	runes  []rune
+	hashes []uint64
+	set    map[uint64]bool
+}
+
+// NewShingles 将文本切分成长度为k的片段
+func NewShingles(text string, k int) *Shingles {
+	s := &Shingles{k: k, set: make(map[uint64]bool)}
+	for _, r := range text {
+		if !unicode.IsSpace(r) {
+			s.runes = append(s.runes, r)
+		}
+	}
+	for i := 0; i+k <= len(s.runes); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(string(s.runes[i : i+k])))
+		s.hashes = append(s.hashes, h.Sum64())
+		s.set[h.Sum64()] = true
+	}
+	return s
+}
+
+// Len 返回不重复的片段数量
+func (s *Shingles) Len() int {
+	return len(s.set)
+}
+
+// SharedPassages 返回与另一段文本共同的段落，按长度从长到短排列，只保留不短于minLen个字符的段落
+func (s *Shingles) SharedPassages(other *Shingles, minLen int) []string {
+	var passages []string
+	start := -1
+	flush := func(end int) {
+		if start != -1 && end-start+s.k >= minLen {
+			passages = append(passages, string(s.runes[start:end+s.k]))
+		}
+		start = -1
+	}
+	for i, h := range s.hashes {
+		if other.set[h] {
+			if start == -1 {
+				start = i
+			}
+		} else {
+			flush(i - 1)
+		}
+	}
+	flush(len(s.hashes) - 1)
+	sort.SliceStable(passages, func(i, j int) bool {
+		return len([]rune(passages[i])) > len([]rune(passages[j]))
+	})
+	return passages
+}
+
+// MinHash 用n个哈希函数计算片段集合的签名，用于快速估计两个集合的Jaccard相似度
+type MinHash struct {
+	seeds []uint64
+}
+
+// NewMinHash 创建n个哈希函数的MinHash，相同的n得到相同的哈希函数
+func NewMinHash(n int) *MinHash {
+	m := &MinHash{seeds: make([]uint64, n)}
+	seed := uint64(0x9E3779B97F4A7C15)
+	for i := range m.seeds {
+		seed = splitmix64(seed)
+		m.seeds[i] = seed
+	}
+	return m
+}
+
+// Signature 计算片段集合的签名
+func (m *MinHash) Signature(s *Shingles) []uint64 {
+	signature := make([]uint64, len(m.seeds))
+	for i := range signature {
+		signature[i] = ^uint64(0)
+	}
+	for h := range s.set {
+		for i, seed := range m.seeds {
+			if v := splitmix64(h ^ seed); v < signature[i] {
+				signature[i] = v
+			}
+		}
+	}
+	return signature
+}
+
+// EstimateSimilarity 根据两个签名估计Jaccard相似度
+func EstimateSimilarity(a, b []uint64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	same := 0
+	for i := range a {
+		// 空集合的签名全部为最大值，不认为相同
+		if a[i] == b[i] && a[i] != ^uint64(0) {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a))
+}
+
+func splitmix64(x uint64) uint64 {
+	x += 0x9E3779B97F4A7C15
+	x = (x ^ (x >> 30)) * 0xBF58476D1CE4E5B9
+	x = (x ^ (x >> 27)) * 0x94D049BB133111EB
+	return x ^ (x >> 31)
+}
@@ -0,0 +1,41 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSharedPassages(t *testing.T) {
+	a := NewShingles("实验目的：掌握PHP的基本语法。实验内容：编写一个计算器。", 4)
+	b := NewShingles("实验内容：编写一个计算器。实验目的：了解Python。", 4)
+
+	expected := []string{"实验内容：编写一个计算器。", "实验目的："}
+	if got := a.SharedPassages(b, 5); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %q, but got %q", expected, got)
+	}
+	if got := a.SharedPassages(NewShingles("毫不相干的内容", 4), 5); len(got) != 0 {
+		t.Errorf("Expected no passages, but got %q", got)
+	}
+}
+
+func TestEstimateSimilarity(t *testing.T) {
+	m := NewMinHash(128)
+	text := "本实验通过编写一个简单的留言板程序，掌握表单的提交、数据的校验以及数据库的增删改查操作。"
+	same := m.Signature(NewShingles(text, 5))
+	copied := m.Signature(NewShingles(text+"实验总结：收获很大。", 5))
+	other := m.Signature(NewShingles("Python的列表推导式和生成器表达式可以让代码更加简洁，也更加高效。", 5))
+
+	if s := EstimateSimilarity(same, same); s != 1 {
+		t.Errorf("Expected similarity 1 for the same text, but got %v", s)
+	}
+	if s := EstimateSimilarity(same, copied); s < 0.6 {
+		t.Errorf("Expected high similarity for the copied text, but got %v", s)
+	}
+	if s := EstimateSimilarity(same, other); s > 0.1 {
+		t.Errorf("Expected low similarity for different texts, but got %v", s)
+	}
+	empty := m.Signature(NewShingles("", 5))
+	if s := EstimateSimilarity(empty, empty); s != 0 {
+		t.Errorf("Expected similarity 0 for empty texts, but got %v", s)
+	}
+}
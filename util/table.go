@@ -0,0 +1,175 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/extrame/xls"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// 表格文件的实际格式
+const (
+	FormatXLSX = "xlsx"
+	FormatXLS  = "xls"
+	FormatHTML = "html"
+	FormatCSV  = "csv"
+)
+
+// DetectTableFormat 根据文件开头的内容判断表格文件的实际格式，教务系统导出的".xls"文件
+// 可能是HTML表格，也可能是旧的BIFF格式，无法识别的文本文件当作csv
+func DetectTableFormat(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, 1024)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return detectTableFormat(head[:n]), nil
+}
+
+func detectTableFormat(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		return FormatXLSX
+	case bytes.HasPrefix(head, []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}):
+		return FormatXLS
+	}
+	text := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(string(head), "\ufeff")))
+	if strings.HasPrefix(text, "<") || strings.Contains(text, "<table") {
+		return FormatHTML
+	}
+	return FormatCSV
+}
+
+// ReadCSVTable 读取CSV表格的所有行，不是UTF-8编码的内容按GB18030(兼容GBK)解码，
+// 如中文Windows系统中Excel另存的CSV文件
+func ReadCSVTable(r io.Reader) ([][]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !utf8.Valid(data) {
+		if data, err = simplifiedchinese.GB18030.NewDecoder().Bytes(data); err != nil {
+			return nil, fmt.Errorf("CSV文件既不是UTF-8也不是GBK编码: %w", err)
+		}
+	}
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	return cr.ReadAll()
+}
+
+// ReadHTMLTable 读取HTML中第一个表格的所有行，合并的单元格按colspan补齐空白单元格，
+// 编码按照HTML中声明的charset转换，如GBK
+func ReadHTMLTable(r io.Reader) ([][]string, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(1024)
+	_, name, _ := charset.DetermineEncoding(head, "text/html")
+	utf8Reader, err := charset.NewReaderLabel(name, br)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := html.Parse(utf8Reader)
+	if err != nil {
+		return nil, err
+	}
+	table := findElement(doc, "table")
+	if table == nil {
+		return nil, fmt.Errorf("no table found")
+	}
+	var rows [][]string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.Data {
+			case "tr":
+				rows = append(rows, htmlRow(c))
+			case "table":
+				// the nested tables are not rows of the table
+			default:
+				walk(c)
+			}
+		}
+	}
+	walk(table)
+	return rows, nil
+}
+
+func htmlRow(tr *html.Node) []string {
+	var row []string
+	for c := tr.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || (c.Data != "td" && c.Data != "th") {
+			continue
+		}
+		row = append(row, strings.TrimSpace(strings.ReplaceAll(nodeText(c), "\u00a0", " ")))
+		for _, attr := range c.Attr {
+			if attr.Key != "colspan" {
+				continue
+			}
+			if span, err := strconv.Atoi(strings.TrimSpace(attr.Val)); err == nil && span > 1 {
+				row = append(row, make([]string, span-1)...)
+			}
+		}
+	}
+	return row
+}
+
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(nodeText(c))
+	}
+	return b.String()
+}
+
+func findElement(n *html.Node, tag string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// ReadXLSFile 读取BIFF格式(Excel 97-2003)的xls文件第一张表的所有行
+func ReadXLSFile(file string) (rows [][]string, err error) {
+	// the parser panics on the broken files
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("broken xls file %s: %v", file, r)
+		}
+	}()
+	wb, err := xls.Open(file, "utf-8")
+	if err != nil {
+		return nil, err
+	}
+	sheet := wb.GetSheet(0)
+	if sheet == nil {
+		return nil, fmt.Errorf("no sheet found in %s", file)
+	}
+	// ReadAllCells reads the sheets in order, so only the rows of the first sheet are read
+	return wb.ReadAllCells(int(sheet.MaxRow) + 1), nil
+}
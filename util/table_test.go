@@ -0,0 +1,73 @@
+package util
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestDetectTableFormat(t *testing.T) {
+	cases := []struct {
+		head   string
+		format string
+	}{
+		{"PK\x03\x04\x14\x00", FormatXLSX},
+		{"\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1\x00", FormatXLS},
+		{"\ufeff <html><body><table>", FormatHTML},
+		{"<meta charset=gbk>", FormatHTML},
+		{"学号,姓名\n2200001,张三\n", FormatCSV},
+	}
+	for _, c := range cases {
+		if format := detectTableFormat([]byte(c.head)); format != c.format {
+			t.Errorf("%q: expected %s, but got %s", c.head, c.format, format)
+		}
+	}
+}
+
+func TestReadHTMLTable(t *testing.T) {
+	page := `<html><head><meta http-equiv="Content-Type" content="text/html; charset=gb2312"></head><body>
+<table border="1">
+<tr><td colspan="3">2022级信安一班学生名单</td></tr>
+<tr><th>学号</th><th>姓名</th><th>班级</th></tr>
+<tr><td>2200001&nbsp;</td><td> 张三 </td><td>信安一班</td></tr>
+</table></body></html>`
+	var buf bytes.Buffer
+	w := simplifiedchinese.GBK.NewEncoder().Writer(&buf)
+	if _, err := w.Write([]byte(page)); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := ReadHTMLTable(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{
+		{"2022级信安一班学生名单", "", ""},
+		{"学号", "姓名", "班级"},
+		{"2200001", "张三", "信安一班"},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Expected %q, but got %q", expected, rows)
+	}
+
+	if _, err := ReadHTMLTable(strings.NewReader("<html><body>no table</body></html>")); err == nil {
+		t.Error("Expected an error without table")
+	}
+}
+
+func TestReadCSVTable(t *testing.T) {
+	text := "学号,姓名\n2200001,张三\n"
+	gbk, err := simplifiedchinese.GBK.NewEncoder().String(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{{"学号", "姓名"}, {"2200001", "张三"}}
+	for _, data := range []string{text, gbk} {
+		rows, err := ReadCSVTable(strings.NewReader(data))
+		if err != nil || !reflect.DeepEqual(rows, expected) {
+			t.Errorf("Expected %v, but got %v, %v", expected, rows, err)
+		}
+	}
+}